@@ -0,0 +1,228 @@
+package main
+
+import (
+	"log"
+	"net/rpc"
+
+	"uk.ac.bris.cs/gameoflife/engine"
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// stripBackend is the engine.Backend used by the broker in strip mode. Every turn it splits the world into
+// one horizontal strip per registered worker, sends each strip with its halo rows, and
+// reassembles the results. As the broker holds the whole world, a failed worker is
+// recovered from by simply retrying the turn with the workers that are left.
+type stripBackend struct {
+	broker *Broker
+	world  *util.Grid
+	p      stubs.Params
+}
+
+func (s *stripBackend) Load(world *util.Grid, p stubs.Params) error {
+	s.world = world
+	s.p = p
+	return nil
+}
+
+func (s *stripBackend) Step() ([]util.Cell, error) {
+	for {
+		flipped, err := s.step()
+		if !isWorkerFailure(err) {
+			return flipped, err
+		}
+		log.Printf("Retrying turn: %v", err)
+	}
+}
+
+func (s *stripBackend) step() ([]util.Cell, error) {
+	workers, _ := s.broker.pool()
+	if len(workers) == 0 {
+		return nil, errNoWorkers
+	}
+	n := len(workers)
+	if n > s.p.ImageHeight {
+		n = s.p.ImageHeight
+	}
+	calls := make([]*rpc.Call, n)
+	for i := 0; i < n; i++ {
+		startY, endY := engine.StripBounds(i, n, s.p)
+		req := stubs.StripRequest{
+			Params: s.p,
+			StartY: startY,
+			EndY:   endY,
+			Rows:   make(map[int][]byte),
+		}
+		for y := startY; y < endY; y++ {
+			req.Rows[y] = s.world.Rows[y]
+		}
+		for _, y := range engine.HaloRows(startY, endY, s.p) {
+			req.Rows[y] = s.world.Rows[y]
+		}
+		calls[i] = workers[i].Go(stubs.CalculateStrip, req, new(stubs.StripResponse), nil)
+	}
+	if err := s.broker.wait(calls, workers[:n]); err != nil {
+		return nil, err
+	}
+	newWorld := &util.Grid{Width: s.p.ImageWidth, Height: s.p.ImageHeight, Bits: engine.GridBits(s.p)}
+	var flipped []util.Cell
+	for _, call := range calls {
+		res := call.Reply.(*stubs.StripResponse)
+		newWorld.Rows = append(newWorld.Rows, res.Strip...)
+		flipped = append(flipped, res.Flipped...)
+	}
+	s.world = newWorld
+	return flipped, nil
+}
+
+func (s *stripBackend) World() (*util.Grid, error) {
+	return s.world, nil
+}
+
+// haloBackend is the engine.Backend used by the broker in halo mode. Each worker keeps its
+// strip between turns and swaps boundary rows directly with its neighbours; the broker only
+// runs the turn barrier and gathers the strips when the world is asked for.
+//
+// Every checkpointEvery turns the world is gathered. If a worker fails, the checkpoint is
+// split among the survivors and the turns since it are replayed, so the controller only
+// sees a slower turn.
+type haloBackend struct {
+	broker  *Broker
+	workers []*rpc.Client
+	p       stubs.Params
+	turn    int
+
+	checkpointEvery int
+	checkpoint      *util.Grid
+	checkpointTurn  int
+}
+
+func (h *haloBackend) Load(world *util.Grid, p stubs.Params) error {
+	h.workers = nil
+	h.p = p
+	h.turn = 0
+	h.checkpoint = world
+	h.checkpointTurn = 0
+	if world == nil {
+		return nil
+	}
+	err := h.distribute(world, 0)
+	if isWorkerFailure(err) {
+		return h.recover(err)
+	}
+	return err
+}
+
+// distribute splits world, which is at the given turn, among the registered workers.
+func (h *haloBackend) distribute(world *util.Grid, turn int) error {
+	workers, address := h.broker.pool()
+	if len(workers) == 0 {
+		return errNoWorkers
+	}
+	n := len(workers)
+	if n > h.p.ImageHeight {
+		n = h.p.ImageHeight
+	}
+	peers := make([]stubs.Peer, n)
+	for i := range peers {
+		startY, endY := engine.StripBounds(i, n, h.p)
+		peers[i] = stubs.Peer{Address: address[i], StartY: startY, EndY: endY}
+	}
+	calls := make([]*rpc.Call, n)
+	for i, peer := range peers {
+		req := stubs.LoadStripRequest{
+			Params: h.p,
+			Turn:   turn,
+			Index:  i,
+			Peers:  peers,
+			Strip:  world.Rows[peer.StartY:peer.EndY],
+		}
+		calls[i] = workers[i].Go(stubs.LoadStrip, req, new(stubs.LoadStripResponse), nil)
+	}
+	h.workers = workers[:n]
+	return h.broker.wait(calls, h.workers)
+}
+
+// recover rebuilds the strips from the last checkpoint and replays the turns since,
+// for as long as workers keep failing and some are left.
+func (h *haloBackend) recover(err error) error {
+	for isWorkerFailure(err) {
+		log.Printf("Recovering from turn %d after %v", h.checkpointTurn, err)
+		err = h.distribute(h.checkpoint, h.checkpointTurn)
+		for turn := h.checkpointTurn; err == nil && turn < h.turn; turn++ {
+			_, err = h.step(turn)
+		}
+	}
+	if err == nil {
+		log.Printf("Recovered at turn %d", h.turn)
+	}
+	return err
+}
+
+// step advances every strip from the given turn to the next.
+func (h *haloBackend) step(turn int) ([]util.Cell, error) {
+	if len(h.workers) == 0 {
+		return nil, errNoWorkers
+	}
+	calls := make([]*rpc.Call, len(h.workers))
+	for i, worker := range h.workers {
+		calls[i] = worker.Go(stubs.StepStrip, stubs.StepStripRequest{Turn: turn}, new(stubs.StepStripResponse), nil)
+	}
+	if err := h.broker.wait(calls, h.workers); err != nil {
+		return nil, err
+	}
+	var flipped []util.Cell
+	for _, call := range calls {
+		flipped = append(flipped, call.Reply.(*stubs.StepStripResponse).Flipped...)
+	}
+	return flipped, nil
+}
+
+func (h *haloBackend) Step() ([]util.Cell, error) {
+	flipped, err := h.step(h.turn)
+	for isWorkerFailure(err) {
+		if err = h.recover(err); err == nil {
+			flipped, err = h.step(h.turn)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.turn++
+	if h.checkpointEvery > 0 && h.turn%h.checkpointEvery == 0 {
+		if world, err := h.World(); err == nil {
+			h.checkpoint = world
+			h.checkpointTurn = h.turn
+		}
+	}
+	return flipped, nil
+}
+
+func (h *haloBackend) World() (*util.Grid, error) {
+	if h.checkpoint == nil {
+		return nil, nil
+	}
+	world, err := h.gather()
+	for isWorkerFailure(err) {
+		if err = h.recover(err); err == nil {
+			world, err = h.gather()
+		}
+	}
+	return world, err
+}
+
+// gather collects every worker's strip into a single world.
+func (h *haloBackend) gather() (*util.Grid, error) {
+	calls := make([]*rpc.Call, len(h.workers))
+	for i, worker := range h.workers {
+		calls[i] = worker.Go(stubs.GetStrip, stubs.GetStripRequest{}, new(stubs.GetStripResponse), nil)
+	}
+	if err := h.broker.wait(calls, h.workers); err != nil {
+		return nil, err
+	}
+	world := &util.Grid{Width: h.p.ImageWidth, Height: h.p.ImageHeight, Bits: engine.GridBits(h.p)}
+	for _, call := range calls {
+		world.Rows = append(world.Rows, call.Reply.(*stubs.GetStripResponse).Strip...)
+	}
+	return world, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/engine"
+	"uk.ac.bris.cs/gameoflife/internal/fixture"
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// loopbackWorker is an engine.Worker served on a loopback port, which can be killed by
+// closing its listener and every connection it accepted.
+type loopbackWorker struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	conns    []net.Conn
+}
+
+func startWorker(t *testing.T) *loopbackWorker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := rpc.NewServer()
+	if err := server.Register(engine.NewWorker()); err != nil {
+		t.Fatal(err)
+	}
+	w := &loopbackWorker{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			w.mutex.Lock()
+			w.conns = append(w.conns, conn)
+			w.mutex.Unlock()
+			go server.ServeConn(conn)
+		}
+	}()
+	return w
+}
+
+func (w *loopbackWorker) kill() {
+	w.listener.Close()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, conn := range w.conns {
+		conn.Close()
+	}
+}
+
+// startBroker returns a broker with n loopback workers registered.
+func startBroker(t *testing.T, n int) (*Broker, []*loopbackWorker) {
+	b := &Broker{mutex: &sync.Mutex{}, timeout: 5 * time.Second}
+	workers := make([]*loopbackWorker, n)
+	for i := range workers {
+		workers[i] = startWorker(t)
+		var res stubs.RegisterResponse
+		if err := b.Register(stubs.RegisterRequest{Address: workers[i].listener.Addr().String()}, &res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b, workers
+}
+
+func stopWorkers(workers []*loopbackWorker) {
+	for _, w := range workers {
+		w.kill()
+	}
+}
+
+// newBackend returns the backend the broker runs in the given mode.
+func newBackend(b *Broker, mode string) engine.Backend {
+	if mode == "halo" {
+		return &haloBackend{broker: b, checkpointEvery: 10}
+	}
+	return &stripBackend{broker: b}
+}
+
+// TestBroker tests that both modes spread the check/images fixtures over loopback workers
+// and give back the same worlds.
+func TestBroker(t *testing.T) {
+	for _, mode := range []string{"strip", "halo"} {
+		b, workers := startBroker(t, 3)
+		controller := engine.NewWorkerWithBackend(newBackend(b, mode))
+		for _, size := range []int{16, 64, 512} {
+			world := fixture.World(t, size, 0)
+			for _, turns := range []int{0, 1, 100} {
+				p := stubs.Params{ImageWidth: size, ImageHeight: size, Turns: turns, Threads: 1}
+				var res stubs.GameOfLifeResponse
+				err := controller.GameOfLife(stubs.GameOfLifeRequest{World: world, Params: p}, &res)
+				name := fmt.Sprintf("%s %dx%dx%d", mode, size, size, turns)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				fixture.AssertSameWorld(t, res.World, fixture.World(t, size, turns), name)
+			}
+		}
+		stopWorkers(workers)
+	}
+}
+
+// TestBrokerAlgorithm tests that a run asking for an algorithm that steps the whole world
+// in one process is refused, rather than run on the broker in place of its workers.
+func TestBrokerAlgorithm(t *testing.T) {
+	for _, mode := range []string{"strip", "halo"} {
+		b, workers := startBroker(t, 3)
+		controller := engine.NewWorkerWithBackend(newBackend(b, mode))
+		world := fixture.World(t, 16, 0)
+		for _, algorithm := range []string{"sparse", "hashlife"} {
+			p := stubs.Params{ImageWidth: 16, ImageHeight: 16, Turns: 1, Threads: 1, Algorithm: algorithm}
+			req := stubs.GameOfLifeRequest{World: world, Params: p}
+			if err := controller.GameOfLife(req, &stubs.GameOfLifeResponse{}); err == nil {
+				t.Errorf("%s: GameOfLife ran the %s algorithm", mode, algorithm)
+			}
+			if err := controller.Start(req, &stubs.StartResponse{}); err == nil {
+				t.Errorf("%s: Start ran the %s algorithm", mode, algorithm)
+			}
+		}
+		// The workers still take the next run.
+		var res stubs.GameOfLifeResponse
+		p := stubs.Params{ImageWidth: 16, ImageHeight: 16, Turns: 1, Threads: 1}
+		if err := controller.GameOfLife(stubs.GameOfLifeRequest{World: world, Params: p}, &res); err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		fixture.AssertSameWorld(t, res.World, fixture.World(t, 16, 1), mode+" 16x16x1")
+		stopWorkers(workers)
+	}
+}
+
+// TestBrokerWorkerFailure tests that both modes carry on with the workers left when one is
+// killed part way through a run, and still reach the fixture's world.
+func TestBrokerWorkerFailure(t *testing.T) {
+	for _, mode := range []string{"strip", "halo"} {
+		b, workers := startBroker(t, 3)
+		backend := newBackend(b, mode)
+		p := stubs.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 1}
+		if err := backend.Load(fixture.World(t, 64, 0), p); err != nil {
+			t.Fatal(err)
+		}
+		for turn := 0; turn < p.Turns; turn++ {
+			if turn == 35 {
+				workers[1].kill()
+			}
+			if _, err := backend.Step(); err != nil {
+				t.Fatalf("%s: turn %d: %v", mode, turn, err)
+			}
+		}
+		if pool, _ := b.pool(); len(pool) != 2 {
+			t.Errorf("%s: %d workers left, want 2", mode, len(pool))
+		}
+		world, err := backend.World()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fixture.AssertSameWorld(t, world, fixture.World(t, 64, 100), mode+" 64x64x100")
+		stopWorkers(workers)
+	}
+}
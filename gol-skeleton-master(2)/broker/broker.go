@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/engine"
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// Broker keeps track of the worker servers that have registered with it, and drops any
+// that stop answering.
+type Broker struct {
+	mutex   *sync.Mutex
+	workers []*rpc.Client
+	address []string
+	// timeout bounds every call to a worker; a worker that misses it is treated as failed.
+	timeout time.Duration
+}
+
+// workerFailure reports that one or more workers died or stopped responding during a call.
+// Backends recover from it by redistributing the world among the surviving workers.
+type workerFailure struct {
+	address []string
+	err     error
+}
+
+func (f workerFailure) Error() string {
+	return fmt.Sprintf("worker %v failed: %v", f.address, f.err)
+}
+
+var errNoWorkers = errors.New("no workers registered with the broker")
+
+// Register is called by a worker server at start-up. The broker dials it back straight away
+// so a worker that cannot be reached is rejected before any turn depends on it.
+func (b *Broker) Register(req stubs.RegisterRequest, res *stubs.RegisterResponse) error {
+	client, err := rpc.Dial("tcp", req.Address)
+	if err != nil {
+		return err
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.workers = append(b.workers, client)
+	b.address = append(b.address, req.Address)
+	res.Workers = len(b.workers)
+	log.Printf("Worker %s registered (%d workers)", req.Address, len(b.workers))
+	return nil
+}
+
+// pool returns a snapshot of the registered workers and their addresses.
+func (b *Broker) pool() ([]*rpc.Client, []string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]*rpc.Client(nil), b.workers...), append([]string(nil), b.address...)
+}
+
+// remove drops a failed worker from the pool and closes its connection, which also
+// aborts any call still waiting on it.
+func (b *Broker) remove(client *rpc.Client) string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	client.Close()
+	for i, worker := range b.workers {
+		if worker == client {
+			address := b.address[i]
+			b.workers = append(b.workers[:i], b.workers[i+1:]...)
+			b.address = append(b.address[:i], b.address[i+1:]...)
+			log.Printf("Worker %s failed and was removed (%d workers left)", address, len(b.workers))
+			return address
+		}
+	}
+	return ""
+}
+
+// wait blocks until every call has finished or the timeout has passed since wait began.
+// Workers whose calls failed at the transport level are removed and reported as a
+// workerFailure; otherwise the first error returned by a worker is passed on.
+func (b *Broker) wait(calls []*rpc.Call, workers []*rpc.Client) error {
+	var err error
+	var failed workerFailure
+	// expired is closed at the deadline, so every call still waiting then sees it, not just
+	// the first.
+	expired := make(chan bool)
+	deadline := time.AfterFunc(b.timeout, func() { close(expired) })
+	defer deadline.Stop()
+	for i, call := range calls {
+		select {
+		case <-call.Done:
+		case <-expired:
+			select {
+			case <-call.Done:
+			default:
+				// Closing the client completes this and every later call on it with ErrShutdown.
+				workers[i].Close()
+				<-call.Done
+			}
+		}
+		if call.Error == nil {
+			continue
+		}
+		if _, ok := call.Error.(rpc.ServerError); ok {
+			if err == nil {
+				err = call.Error
+			}
+			continue
+		}
+		// A worker already removed, by an earlier call or by another wait, is not reported again.
+		if address := b.remove(workers[i]); address != "" {
+			failed.address = append(failed.address, address)
+		}
+		failed.err = call.Error
+	}
+	if failed.err != nil {
+		return failed
+	}
+	return err
+}
+
+// isWorkerFailure reports whether err means a worker has gone and the turn should be retried.
+func isWorkerFailure(err error) bool {
+	_, ok := err.(workerFailure)
+	return ok
+}
+
+// monitor pings every registered worker at the given interval so that a dead worker is
+// noticed even while no turns are being processed.
+func (b *Broker) monitor(interval time.Duration) {
+	for range time.Tick(interval) {
+		workers, _ := b.pool()
+		calls := make([]*rpc.Call, len(workers))
+		for i, worker := range workers {
+			calls[i] = worker.Go(stubs.Ping, stubs.PingRequest{}, new(stubs.PingResponse), nil)
+		}
+		b.wait(calls, workers)
+	}
+}
+
+// shutdown asks every registered worker to exit.
+func (b *Broker) shutdown() {
+	workers, _ := b.pool()
+	for _, client := range workers {
+		client.Call(stubs.Shutdown, stubs.ShutdownRequest{}, &stubs.ShutdownResponse{})
+	}
+}
+
+func main() {
+	port := flag.String("port", "8030", "port to listen on")
+	mode := flag.String("mode", "halo", "how turns are split: halo (workers keep strips and swap boundary rows) or strip (world sent out every turn)")
+	timeout := flag.Duration("timeout", 5*time.Second, "how long a worker may take to answer before it is treated as failed")
+	heartbeat := flag.Duration("heartbeat", time.Second, "how often registered workers are pinged")
+	checkpoint := flag.Int("checkpoint", 50, "in halo mode, gather the world every this many turns so a failed worker's strip can be rebuilt")
+	flag.Parse()
+	listener, err := net.Listen("tcp", ":"+*port)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	log.Printf("Broker listening on port %s", *port)
+	broker := &Broker{mutex: &sync.Mutex{}, timeout: *timeout}
+	var backend engine.Backend
+	switch *mode {
+	case "halo":
+		backend = &haloBackend{broker: broker, checkpointEvery: *checkpoint}
+	case "strip":
+		backend = &stripBackend{broker: broker}
+	default:
+		log.Fatalf("unknown mode %q", *mode)
+	}
+	// Controllers talk to the broker exactly as they would to a single worker.
+	worker := engine.NewWorkerWithBackend(backend)
+	rpc.Register(broker)
+	rpc.Register(worker)
+	go rpc.Accept(listener)
+	go broker.monitor(*heartbeat)
+	<-worker.Killed()
+	broker.shutdown()
+	// Give the controller a moment to receive the reply to its k key press.
+	time.Sleep(100 * time.Millisecond)
+	log.Printf("Shutting down")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// hungWorker accepts connections and reads every call sent to it, but never replies.
+func hungWorker(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+	return listener
+}
+
+// TestWaitHungWorkers tests that wait gives up on every hung worker at the one deadline,
+// and reports and removes each of them once.
+func TestWaitHungWorkers(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	b := &Broker{mutex: &sync.Mutex{}, timeout: timeout}
+	for i := 0; i < 2; i++ {
+		listener := hungWorker(t)
+		defer listener.Close()
+		var res stubs.RegisterResponse
+		if err := b.Register(stubs.RegisterRequest{Address: listener.Addr().String()}, &res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	workers, address := b.pool()
+	calls := make([]*rpc.Call, len(workers))
+	for i, worker := range workers {
+		calls[i] = worker.Go(stubs.Ping, stubs.PingRequest{}, new(stubs.PingResponse), nil)
+	}
+	result := make(chan error)
+	start := time.Now()
+	go func() { result <- b.wait(calls, workers) }()
+	select {
+	case err := <-result:
+		if elapsed := time.Since(start); elapsed > 2*timeout {
+			t.Errorf("wait took %v, want about %v", elapsed, timeout)
+		}
+		failure, ok := err.(workerFailure)
+		if !ok {
+			t.Fatalf("wait returned %v, want a workerFailure", err)
+		}
+		if len(failure.address) != 2 || failure.address[0] != address[0] || failure.address[1] != address[1] {
+			t.Errorf("failed workers %v, want %v", failure.address, address)
+		}
+	case <-time.After(5 * timeout):
+		t.Fatal("wait did not return after the timeout")
+	}
+	if workers, _ := b.pool(); len(workers) != 0 {
+		t.Errorf("%d workers left in the pool, want 0", len(workers))
+	}
+}
+
+// TestWaitRemovedWorker tests that a worker already removed from the pool is not reported
+// as failed again.
+func TestWaitRemovedWorker(t *testing.T) {
+	b := &Broker{mutex: &sync.Mutex{}, timeout: 100 * time.Millisecond}
+	listener := hungWorker(t)
+	defer listener.Close()
+	var res stubs.RegisterResponse
+	if err := b.Register(stubs.RegisterRequest{Address: listener.Addr().String()}, &res); err != nil {
+		t.Fatal(err)
+	}
+	workers, _ := b.pool()
+	b.remove(workers[0])
+	call := workers[0].Go(stubs.Ping, stubs.PingRequest{}, new(stubs.PingResponse), nil)
+	err := b.wait([]*rpc.Call{call}, workers)
+	failure, ok := err.(workerFailure)
+	if !ok {
+		t.Fatalf("wait returned %v, want a workerFailure", err)
+	}
+	if len(failure.address) != 0 {
+		t.Errorf("failed workers %v, want none", failure.address)
+	}
+}
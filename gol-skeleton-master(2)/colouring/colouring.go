@@ -0,0 +1,203 @@
+// Package colouring keeps the history of every cell as the events report it changing, so
+// that the visualisers can colour cells by more than their state: by how long they have
+// been alive or dead, or by how often they have changed.
+package colouring
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Mode is a way of colouring cells.
+type Mode int
+
+const (
+	// State colours cells by their state alone, in the grey levels of util.StateGrey.
+	State Mode = iota
+	// Age colours alive cells from yellow when newborn to blue once settled, and dead
+	// cells red fading to black in the turns after they die.
+	Age
+	// Heat colours cells from black through red and yellow to white by how often they
+	// have changed since the run began.
+	Heat
+)
+
+var modeNames = []string{"state", "age", "heat"}
+
+func (m Mode) String() string {
+	if m >= 0 && int(m) < len(modeNames) {
+		return modeNames[m]
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Next returns the mode after m, going back to State after the last.
+func (m Mode) Next() Mode {
+	return (m + 1) % Mode(len(modeNames))
+}
+
+// ParseMode parses the name of a mode. An empty string is State.
+func ParseMode(s string) (Mode, error) {
+	if s == "" {
+		return State, nil
+	}
+	for m, name := range modeNames {
+		if strings.EqualFold(s, name) {
+			return Mode(m), nil
+		}
+	}
+	return State, fmt.Errorf("invalid colouring %q: want %s", s, strings.Join(modeNames, ", "))
+}
+
+const (
+	// never is the turn given to cells that have never changed.
+	never = math.MinInt32
+	// ageSpan is the age in turns at which an alive cell is drawn as settled.
+	ageSpan = 64
+	// deathSpan is the number of turns a dead cell takes to fade to black.
+	deathSpan = 24
+)
+
+// Board is the state and history of every cell, kept up to date from the events.
+type Board struct {
+	Width, Height int
+	greys         []uint8
+	// changed is the turn each cell was last born or died, or never.
+	changed []int32
+	// flips counts the changes to each cell after the world was loaded, and maxFlips is the
+	// most of any cell.
+	flips    []uint32
+	maxFlips uint32
+	turn     int
+}
+
+// NewBoard returns a board of width by height dead cells.
+func NewBoard(width, height int) *Board {
+	b := &Board{
+		Width:   width,
+		Height:  height,
+		greys:   make([]uint8, width*height),
+		changed: make([]int32, width*height),
+		flips:   make([]uint32, width*height),
+	}
+	for i := range b.changed {
+		b.changed[i] = never
+	}
+	return b
+}
+
+// Contains reports whether cell is on the board.
+func (b *Board) Contains(cell util.Cell) bool {
+	return cell.X >= 0 && cell.X < b.Width && cell.Y >= 0 && cell.Y < b.Height
+}
+
+// Flip changes a cell between alive and dead in the given turn.
+func (b *Board) Flip(cell util.Cell, turn int) {
+	if b.Contains(cell) {
+		b.Set(cell, ^b.greys[cell.Y*b.Width+cell.X], turn)
+	}
+}
+
+// Set gives a cell the grey level of its new state in the given turn.
+func (b *Board) Set(cell util.Cell, grey uint8, turn int) {
+	if !b.Contains(cell) {
+		return
+	}
+	b.Complete(turn)
+	i := cell.Y*b.Width + cell.X
+	if b.greys[i] == grey {
+		return
+	}
+	// Dying cells of a Generations rule keep the age of when they were born.
+	if b.greys[i] == 0 || grey == 0 || grey == 255 {
+		b.changed[i] = int32(turn)
+	}
+	b.greys[i] = grey
+	// The world as loaded is not counted as activity.
+	if turn > 0 {
+		b.flips[i]++
+		if b.flips[i] > b.maxFlips {
+			b.maxFlips = b.flips[i]
+		}
+	}
+}
+
+// Complete moves the board on to the given turn, so cells that did not change grow older.
+func (b *Board) Complete(turn int) {
+	if turn > b.turn {
+		b.turn = turn
+	}
+}
+
+// Grey returns the grey level of the state of the cell at (x, y).
+func (b *Board) Grey(x, y int) uint8 {
+	return b.greys[y*b.Width+x]
+}
+
+// RGB returns the colour of the cell at (x, y) in the given mode.
+func (b *Board) RGB(x, y int, mode Mode) (uint8, uint8, uint8) {
+	return b.RGBAs(x, y, b.Grey(x, y), mode)
+}
+
+// RGBAs returns the colour of the cell at (x, y) in the given mode as if its state had the
+// grey level grey, for drawing a world that may be a few turns ahead of the board.
+func (b *Board) RGBAs(x, y int, grey uint8, mode Mode) (uint8, uint8, uint8) {
+	i := y*b.Width + x
+	switch mode {
+	case Age:
+		if b.changed[i] == never {
+			return 0, 0, 0
+		}
+		since := float64(b.turn - int(b.changed[i]))
+		if grey == 0 {
+			if since >= deathSpan {
+				return 0, 0, 0
+			}
+			return blend(deathRamp, since/deathSpan)
+		}
+		r, g, bl := blend(ageRamp, since/ageSpan)
+		if grey == 255 {
+			return r, g, bl
+		}
+		// A dying cell is its age's colour, darkened as its state is.
+		return scale(r, grey), scale(g, grey), scale(bl, grey)
+	case Heat:
+		if b.flips[i] == 0 {
+			return 0, 0, 0
+		}
+		return blend(heatRamp, math.Log1p(float64(b.flips[i]))/math.Log1p(float64(b.maxFlips)))
+	}
+	return grey, grey, grey
+}
+
+// A ramp is a run of colours that a value from 0 to 1 is mapped along.
+type ramp [][3]uint8
+
+var (
+	ageRamp   = ramp{{255, 240, 96}, {96, 224, 112}, {64, 112, 255}}
+	deathRamp = ramp{{208, 40, 40}, {96, 16, 24}, {0, 0, 0}}
+	heatRamp  = ramp{{64, 0, 0}, {200, 32, 0}, {255, 160, 0}, {255, 255, 255}}
+)
+
+// blend returns the colour at t along the ramp, t being clamped to 0 to 1.
+func blend(colours ramp, t float64) (uint8, uint8, uint8) {
+	t = math.Max(0, math.Min(1, t)) * float64(len(colours)-1)
+	i := int(t)
+	if i == len(colours)-1 {
+		c := colours[i]
+		return c[0], c[1], c[2]
+	}
+	f := t - float64(i)
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*f + 0.5)
+	}
+	a, c := colours[i], colours[i+1]
+	return mix(a[0], c[0]), mix(a[1], c[1]), mix(a[2], c[2])
+}
+
+func scale(level, by uint8) uint8 {
+	return uint8(int(level) * int(by) / 255)
+}
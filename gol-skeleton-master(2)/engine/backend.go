@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"fmt"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Backend holds the world for a Worker and advances it one turn at a time.
+// The broker supplies its own Backend to spread each turn over several worker servers.
+type Backend interface {
+	// Load replaces the world and the parameters it is stepped with.
+	Load(world *util.Grid, p stubs.Params) error
+	// Step advances the world by one turn and returns the cells that flipped.
+	Step() ([]util.Cell, error)
+	// World returns the current world.
+	World() (*util.Grid, error)
+}
+
+// Leaper is a Backend that can advance the world by many turns at once. The Worker leaps
+// rather than steps when its backend is a Leaper.
+type Leaper interface {
+	Backend
+	// Leap advances the world by between one and turns turns, returning how many it
+	// advanced and the cells that differ from before.
+	Leap(turns int) (int, []util.Cell, error)
+}
+
+// Editor is a Backend that can change cells of its world in place. The Worker edits
+// other backends by loading an edited copy of their world.
+type Editor interface {
+	Backend
+	// Edit sets cells alive, or dead if alive is false, returning those it changed.
+	Edit(cells []util.Cell, alive bool) ([]util.Cell, error)
+}
+
+// localBackend steps the whole world in this process.
+type localBackend struct {
+	world *util.Grid
+	p     stubs.Params
+}
+
+func (b *localBackend) Load(world *util.Grid, p stubs.Params) error {
+	b.world = world
+	b.p = p
+	return nil
+}
+
+func (b *localBackend) Step() ([]util.Cell, error) {
+	newWorld, flipped := CalculateNextState(b.world, b.p)
+	b.world = newWorld
+	return flipped, nil
+}
+
+func (b *localBackend) World() (*util.Grid, error) {
+	return b.world, nil
+}
+
+// checkAlgorithm reports an error if p.Algorithm is unknown or cannot run p's rule and topology.
+func checkAlgorithm(p stubs.Params, rule Rule, topology Topology) error {
+	switch p.Algorithm {
+	case "":
+		return nil
+	case "sparse":
+		if rule.States > 2 {
+			return fmt.Errorf("the sparse algorithm does not support Generations rules such as %v", rule)
+		}
+		if rule.Birth[0] {
+			return fmt.Errorf("the sparse algorithm cannot run %v, as B0 fills an unbounded world", rule)
+		}
+		if topology != Torus {
+			return fmt.Errorf("the sparse algorithm has an unbounded world, so takes no topology")
+		}
+		return nil
+	case "hashlife":
+		return checkHashLife(p, rule, topology)
+	default:
+		return fmt.Errorf("invalid algorithm %q: want sparse, hashlife, or none for a fixed-size world", p.Algorithm)
+	}
+}
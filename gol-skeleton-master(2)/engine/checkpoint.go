@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Checkpointer periodically saves a Worker's world to disk so a simulation survives the
+// worker process being killed. Checkpoints are gzipped gob files holding one bit per cell.
+type Checkpointer struct {
+	// Dir is the directory checkpoints are written to.
+	Dir string
+	// EveryTurns and Every set how often to save; either may be zero to disable it.
+	EveryTurns int
+	Every      time.Duration
+	// Keep is how many of the newest checkpoints to retain.
+	Keep int
+
+	// mutex guards lastTurn and lastTime, as checkpoints are saved by the run loop, by k
+	// and by steps taken while paused, outside the Worker's mutex.
+	mutex    sync.Mutex
+	lastTurn int
+	lastTime time.Time
+}
+
+// checkpoint is what is stored in a checkpoint file.
+type checkpoint struct {
+	Params stubs.Params
+	Turn   int
+	World  *util.Grid
+}
+
+// seed counts the next checkpoint from turn, the turn a session starts or resumes from, so
+// a session resumed from a checkpoint does not at once write the same one again.
+func (c *Checkpointer) seed(turn int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastTurn = turn
+	c.lastTime = time.Now()
+}
+
+// due reports whether a checkpoint should be written at turn.
+func (c *Checkpointer) due(turn int) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.EveryTurns > 0 && turn-c.lastTurn >= c.EveryTurns {
+		return true
+	}
+	return c.Every > 0 && time.Since(c.lastTime) >= c.Every
+}
+
+// save writes the world at turn to a new checkpoint file and prunes old ones.
+func (c *Checkpointer) save(world *util.Grid, turn int, p stubs.Params) error {
+	c.seed(turn)
+	if err := os.MkdirAll(c.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	name := filepath.Join(c.Dir, fmt.Sprintf("checkpoint-%019d-%d.gob.gz", time.Now().UnixNano(), turn))
+	// Write to a temporary file first so a crash never leaves a truncated checkpoint behind.
+	file, err := os.Create(name + ".tmp")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(file)
+	err = gob.NewEncoder(zw).Encode(checkpoint{Params: p, Turn: turn, World: world})
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(name+".tmp", name)
+	}
+	if err != nil {
+		os.Remove(name + ".tmp")
+		return err
+	}
+	return c.prune()
+}
+
+// prune deletes all but the newest Keep checkpoints.
+func (c *Checkpointer) prune() error {
+	files, err := checkpointFiles(c.Dir)
+	if err != nil || c.Keep <= 0 || len(files) <= c.Keep {
+		return err
+	}
+	for _, file := range files[:len(files)-c.Keep] {
+		// Another save may be pruning the same files.
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkpointFiles lists the checkpoints in dir, oldest first.
+func checkpointFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "checkpoint-*.gob.gz"))
+	sort.Strings(files)
+	return files, err
+}
+
+// loadLatestCheckpoint reads the newest checkpoint in dir.
+func loadLatestCheckpoint(dir string) (*checkpoint, error) {
+	files, err := checkpointFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no checkpoints in %s", dir)
+	}
+	file, err := os.Open(files[len(files)-1])
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	var saved checkpoint
+	if err := gob.NewDecoder(zr).Decode(&saved); err != nil {
+		return nil, fmt.Errorf("%s: %v", files[len(files)-1], err)
+	}
+	return &saved, nil
+}
@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/internal/fixture"
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// checkpointTurns returns the turns of the checkpoints in dir, oldest first.
+func checkpointTurns(t *testing.T, dir string) []int {
+	files, err := checkpointFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var turns []int
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".gob.gz")
+		turn, err := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+		if err != nil {
+			t.Fatalf("checkpoint %s: %v", file, err)
+		}
+		turns = append(turns, turn)
+	}
+	return turns
+}
+
+// TestCheckpointKeep tests that only the newest Keep checkpoints are left on disk, and that
+// the newest reads back as it was saved.
+func TestCheckpointKeep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &Checkpointer{Dir: dir, Keep: 2}
+	p := stubs.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 2}
+	world := fixture.World(t, 64, 0)
+	const saves = 5
+	for turn := 0; turn < saves; turn++ {
+		if turn > 0 {
+			world, _ = CalculateNextState(world, p)
+		}
+		if err := c.save(world, turn, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if turns := checkpointTurns(t, dir); fmt.Sprint(turns) != fmt.Sprint([]int{saves - 2, saves - 1}) {
+		t.Errorf("checkpoints of turns %v left, want the last two", turns)
+	}
+	if temporary, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(temporary) > 0 {
+		t.Errorf("temporary files left: %v", temporary)
+	}
+
+	saved, err := loadLatestCheckpoint(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Turn != saves-1 || saved.Params != p {
+		t.Errorf("loaded turn %d of %+v, want turn %d of %+v", saved.Turn, saved.Params, saves-1, p)
+	}
+	fixture.AssertSameWorld(t, saved.World, world, "newest checkpoint")
+}
+
+// TestCheckpointResume tests that a worker restored from a checkpoint carries on from it to
+// the fixture, saving its next checkpoint a full interval later rather than at once.
+func TestCheckpointResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := stubs.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 2}
+	world := fixture.World(t, 64, 0)
+	for turn := 0; turn < 25; turn++ {
+		world, _ = CalculateNextState(world, p)
+	}
+	if err := (&Checkpointer{Dir: dir}).save(world, 25, p); err != nil {
+		t.Fatal(err)
+	}
+
+	worker := NewWorker()
+	worker.EnableCheckpoints(&Checkpointer{Dir: dir, EveryTurns: 10})
+	turn, err := worker.Restore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if turn != 25 {
+		t.Fatalf("restored turn %d, want 25", turn)
+	}
+	// The thread count may differ from the checkpoint's.
+	p.Threads = 4
+	var res stubs.GameOfLifeResponse
+	if err := worker.GameOfLife(stubs.GameOfLifeRequest{World: fixture.World(t, 64, 0), Params: p}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Turns != 100 {
+		t.Errorf("%d turns completed, want 100", res.Turns)
+	}
+	fixture.AssertSameWorld(t, res.World, fixture.World(t, 64, 100), "resumed 64x64x100")
+
+	want := []int{25, 35, 45, 55, 65, 75, 85, 95}
+	if turns := checkpointTurns(t, dir); fmt.Sprint(turns) != fmt.Sprint(want) {
+		t.Errorf("checkpoints of turns %v saved, want %v", turns, want)
+	}
+}
+
+// TestCheckpointKill tests that k saves a checkpoint of the turn it stopped at while the
+// run loop is saving one every turn; run with -race, it also checks they do not race.
+func TestCheckpointKill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := NewWorker()
+	w.EnableCheckpoints(&Checkpointer{Dir: dir, EveryTurns: 1, Keep: 3})
+	p := stubs.Params{ImageWidth: 64, ImageHeight: 64, Turns: 1000000, Threads: 2}
+	req := stubs.GameOfLifeRequest{World: fixture.World(t, 64, 0), Params: p}
+	finished := make(chan error)
+	go func() {
+		finished <- w.GameOfLife(req, &stubs.GameOfLifeResponse{})
+	}()
+	eventually(t, "the run is 20 turns in", func() bool { return w.turn() >= 20 })
+	var res stubs.KeyPressResponse
+	if err := w.KeyPress(stubs.KeyPressRequest{Key: 'k'}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-finished; err != nil {
+		t.Fatal(err)
+	}
+	if turns := checkpointTurns(t, dir); len(turns) != 3 {
+		t.Errorf("checkpoints of turns %v left, want 3", turns)
+	}
+	saved, err := loadLatestCheckpoint(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Turn != res.Turn {
+		t.Errorf("newest checkpoint is of turn %d, want turn %d that k stopped at", saved.Turn, res.Turn)
+	}
+	fixture.AssertSameWorld(t, saved.World, res.World, "checkpoint saved by k")
+}
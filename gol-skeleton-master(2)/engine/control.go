@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+const (
+	// firstLimit is the speed limit, in turns a second, that slowing down a session with
+	// none starts at. Speeding up past maxLimit lifts the limit, and slowing down stops at
+	// minLimit.
+	firstLimit = 64
+	maxLimit   = 1024
+	minLimit   = 0.25
+	// paceCheck is the longest the run loop waits for the next turn before looking at the
+	// speed limit again, so a change to it applies at once.
+	paceCheck = 100 * time.Millisecond
+)
+
+// Control steps a paused session by a turn, changes its speed limit, or sets the turn it
+// runs until before pausing itself.
+func (w *Worker) Control(req stubs.ControlRequest, res *stubs.ControlResponse) error {
+	w.mutex.Lock()
+	if w.session == "" || req.Session != w.session {
+		w.mutex.Unlock()
+		return errNoSession
+	}
+	if req.Until > 0 && req.Until <= w.currentTurn {
+		w.mutex.Unlock()
+		return fmt.Errorf("turn %d has already been reached", req.Until)
+	}
+	switch {
+	case req.TurnsPerSecond > 0:
+		w.limit = req.TurnsPerSecond
+	case req.TurnsPerSecond < 0:
+		w.limit = 0
+	}
+	switch {
+	case req.Until > 0:
+		w.until = req.Until
+	case req.Until < 0:
+		w.until = 0
+	}
+	var world *util.Grid
+	var stepped bool
+	var err error
+	if req.Step {
+		world, stepped, err = w.stepPaused()
+	}
+	res.Turn = w.currentTurn
+	res.Paused = w.paused
+	res.TurnsPerSecond = w.limit
+	res.Until = w.until
+	w.mutex.Unlock()
+	if stepped {
+		w.stepped(world, res.Turn)
+	}
+	return err
+}
+
+// stepPaused advances the session by one turn if it is paused and has turns left,
+// reporting whether it did, and returns the world if a checkpoint is due. The caller must
+// hold the mutex.
+func (w *Worker) stepPaused() (*util.Grid, bool, error) {
+	if !w.paused || w.currentTurn >= w.Param.Turns {
+		return nil, false, nil
+	}
+	world, _, err := w.step(1)
+	if err != nil {
+		return nil, false, err
+	}
+	return world, true, nil
+}
+
+// stepped finishes a step taken while paused, saving world if a checkpoint was due, and
+// lets the run loop see whether the session has now run all its turns.
+func (w *Worker) stepped(world *util.Grid, turn int) {
+	if world != nil {
+		w.saveCheckpoint(world, turn)
+	}
+	w.wakeRun()
+	log.Printf("Turn %d stepped", turn)
+}
+
+// step advances the world by up to turns turns, by one if the speed is limited, and not
+// past the turn to run until, streaming the cells that changed. It reports whether that
+// turn was reached, pausing the session, and returns the world if a checkpoint is due.
+// The caller must hold the mutex.
+func (w *Worker) step(turns int) (*util.Grid, bool, error) {
+	if w.limit > 0 {
+		turns = 1
+	}
+	if w.until > w.currentTurn && w.until-w.currentTurn < turns {
+		turns = w.until - w.currentTurn
+	}
+	advanced, flipped, err := w.advance(turns)
+	if err != nil {
+		return nil, false, err
+	}
+	w.currentTurn += advanced
+	w.lastStep = time.Now()
+	reached := w.until > 0 && w.currentTurn >= w.until
+	if reached {
+		w.until = 0
+		w.paused = true
+		log.Printf("Turn %d reached, paused", w.currentTurn)
+	}
+	if w.stream != nil && !w.stream.done {
+		w.stream.add(stubs.TurnFlips{Turn: w.currentTurn, Cells: flipped, Edits: w.edits, Paused: reached})
+	}
+	if w.checkpoints != nil && w.checkpoints.due(w.currentTurn) {
+		world, err := w.backend.World()
+		return world, reached, err
+	}
+	return nil, reached, nil
+}
+
+// pace returns how long to wait before the next turn to keep to the speed limit, at most
+// paceCheck.
+func (w *Worker) pace() time.Duration {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.limit <= 0 {
+		return 0
+	}
+	wait := time.Until(w.lastStep.Add(time.Duration(float64(time.Second) / w.limit)))
+	if wait > paceCheck {
+		wait = paceCheck
+	}
+	return wait
+}
+
+// slower returns the speed limit halved, or firstLimit if there was none.
+func slower(limit float64) float64 {
+	if limit == 0 {
+		return firstLimit
+	}
+	return math.Max(limit/2, minLimit)
+}
+
+// faster returns the speed limit doubled, or no limit once that passes maxLimit.
+func faster(limit float64) float64 {
+	if limit == 0 || limit*2 > maxLimit {
+		return 0
+	}
+	return limit * 2
+}
+
+// controlKey handles the keys n, which steps a paused session, and [ and ], which slow it
+// down and speed it up.
+func (w *Worker) controlKey(key rune, res *stubs.KeyPressResponse) error {
+	w.mutex.Lock()
+	var world *util.Grid
+	var stepped bool
+	var err error
+	switch key {
+	case 'n':
+		world, stepped, err = w.stepPaused()
+	case '[':
+		w.limit = slower(w.limit)
+	case ']':
+		w.limit = faster(w.limit)
+	}
+	res.Turn = w.currentTurn
+	res.Paused = w.paused
+	res.TurnsPerSecond = w.limit
+	res.Until = w.until
+	w.mutex.Unlock()
+	if stepped {
+		w.stepped(world, res.Turn)
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Edit sets cells of the session's world alive or dead, as the user draws on it. The
+// session carries on from the edited world, and the cells that changed are streamed to the
+// controller in order with the turns.
+func (w *Worker) Edit(req stubs.EditRequest, res *stubs.EditResponse) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.session == "" || req.Session != w.session {
+		return errNoSession
+	}
+	changed, err := w.edit(req.Cells, req.Alive)
+	if err != nil {
+		return err
+	}
+	res.Turn = w.currentTurn
+	res.Changed = len(changed)
+	if len(changed) == 0 {
+		return nil
+	}
+	w.edits++
+	if w.stream != nil && !w.stream.done {
+		w.stream.add(stubs.TurnFlips{Turn: w.currentTurn, Cells: changed, Edits: w.edits, Edit: true, Alive: req.Alive})
+	}
+	return nil
+}
+
+// edit changes the backend's world, returning the cells that changed. Cells outside a
+// world of fixed size are left out. The caller must hold the mutex.
+func (w *Worker) edit(cells []util.Cell, alive bool) ([]util.Cell, error) {
+	if editor, ok := w.backend.(Editor); ok {
+		return editor.Edit(cells, alive)
+	}
+	world, err := w.backend.World()
+	if err != nil {
+		return nil, err
+	}
+	// The backend may still hold or have handed out the world, so a copy is edited.
+	edited := world.Clone()
+	var state uint8
+	if alive {
+		state = 1
+	}
+	var changed []util.Cell
+	for _, cell := range cells {
+		x, y := cell.X-edited.OriginX, cell.Y-edited.OriginY
+		if x < 0 || x >= edited.Width || y < 0 || y >= edited.Height {
+			continue
+		}
+		if edited.Rows[y] == nil {
+			edited.Rows[y] = util.NewRow(edited.Width, edited.CellBits())
+		}
+		if edited.State(x, y) != state {
+			edited.SetState(x, y, state)
+			changed = append(changed, cell)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	return changed, w.backend.Load(edited, w.Param)
+}
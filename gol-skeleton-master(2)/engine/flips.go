@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"log"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+const (
+	// flipBuffer is how many turns of flipped cells are held for a controller before the
+	// session waits for it to catch up, so a slow viewer throttles the simulation.
+	flipBuffer = 32
+	// flipStall is how long a full buffer may go unread before the controller is assumed
+	// gone and the session carries on without streaming.
+	flipStall = 30 * time.Second
+)
+
+// flipStream holds the cells flipped by each turn of a session until a controller fetches
+// them with GetFlips. All fields are guarded by the Worker's mutex.
+type flipStream struct {
+	session string
+	turns   []stubs.TurnFlips
+	// done is set once no more turns will be added.
+	done bool
+	// changed is closed and replaced whenever turns or done change.
+	changed chan bool
+}
+
+func newFlipStream(session string) *flipStream {
+	return &flipStream{session: session, changed: make(chan bool)}
+}
+
+func (s *flipStream) notify() {
+	close(s.changed)
+	s.changed = make(chan bool)
+}
+
+func (s *flipStream) add(flips stubs.TurnFlips) {
+	s.turns = append(s.turns, flips)
+	s.notify()
+}
+
+func (s *flipStream) end() {
+	if !s.done {
+		s.done = true
+		s.notify()
+	}
+}
+
+func (s *flipStream) full() bool {
+	return !s.done && len(s.turns) >= flipBuffer
+}
+
+// endStream marks the stream for session as ended, if it is the current one. Turns already
+// recorded can still be fetched. The caller must hold the mutex.
+func (w *Worker) endStream(session string) {
+	if w.stream != nil && w.stream.session == session {
+		w.stream.end()
+	}
+}
+
+// backlog returns a channel to wait on before stepping when the controller has fallen
+// flipBuffer turns behind, or nil if the session may step.
+func (w *Worker) backlog() (<-chan bool, *flipStream) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.stream == nil || !w.stream.full() {
+		return nil, nil
+	}
+	return w.stream.changed, w.stream
+}
+
+// stall gives up streaming when stream has been left full for flipStall.
+func (w *Worker) stall(stream *flipStream) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.stream == stream && stream.full() {
+		log.Printf("Controller stopped reading turns of session %s; no longer streaming", stream.session)
+		stream.end()
+	}
+}
+
+// received reports whether the controller making req already has flips. Edits made after a
+// turn follow it in the stream, so entries are in order of their turn and then their edits.
+func received(flips stubs.TurnFlips, req stubs.GetFlipsRequest) bool {
+	return flips.Turn < req.SinceTurn || flips.Turn == req.SinceTurn && flips.Edits <= req.SinceEdits
+}
+
+// GetFlips returns the cells flipped by each turn after req.SinceTurn, and by each edit
+// after req.SinceEdits, waiting until there is at least one such entry or the stream has
+// ended. Entries up to them are taken as received and discarded, which lets a session that
+// was waiting on them carry on.
+func (w *Worker) GetFlips(req stubs.GetFlipsRequest, res *stubs.GetFlipsResponse) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	stream := w.stream
+	if stream == nil || stream.session != req.Session {
+		return errNoSession
+	}
+	for {
+		done := 0
+		for done < len(stream.turns) && received(stream.turns[done], req) {
+			done++
+		}
+		if done > 0 {
+			stream.turns = stream.turns[done:]
+			stream.notify()
+		}
+		if len(stream.turns) > 0 || stream.done {
+			res.Turns = stream.turns
+			res.Done = stream.done
+			return nil
+		}
+		changed := stream.changed
+		w.mutex.Unlock()
+		<-changed
+		w.mutex.Lock()
+	}
+}
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"fmt"
+	"net/rpc"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// stripState is the part of the world a worker owns when the broker uses halo exchange.
+// Neighbouring workers fetch boundary rows from it directly with GetHalo, so the world
+// itself never travels through the broker between turns.
+type stripState struct {
+	mutex  *sync.Mutex
+	p      stubs.Params
+	index  int
+	peers  []stubs.Peer
+	turn   int
+	startY int
+	endY   int
+	// rows holds rows startY to endY-1; previous holds the same rows one turn earlier,
+	// for neighbours that have not yet fetched their halo for this turn.
+	rows     [][]byte
+	previous [][]byte
+	clients  map[string]*rpc.Client
+}
+
+// owner returns the index of the peer whose strip contains row y.
+func (s *stripState) owner(y int) int {
+	for i, peer := range s.peers {
+		if y >= peer.StartY && y < peer.EndY {
+			return i
+		}
+	}
+	return -1
+}
+
+// halo fetches row y as it was at turn from whichever worker owns it.
+func (s *stripState) halo(y, turn int) ([]byte, error) {
+	i := s.owner(y)
+	if i == s.index {
+		return s.rows[y-s.startY], nil
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("no worker owns row %d", y)
+	}
+	var res stubs.HaloResponse
+	err := s.clients[s.peers[i].Address].Call(stubs.GetHalo, stubs.HaloRequest{Turn: turn, Y: y}, &res)
+	return res.Row, err
+}
+
+func (s *stripState) close() {
+	for _, client := range s.clients {
+		client.Close()
+	}
+}
+
+// LoadStrip hands this worker its strip of the world and the addresses of the other workers.
+func (w *Worker) LoadStrip(req stubs.LoadStripRequest, res *stubs.LoadStripResponse) error {
+	if err := CheckParams(req.Params); err != nil {
+		return err
+	}
+	self := req.Peers[req.Index]
+	strip := &stripState{
+		mutex:   &sync.Mutex{},
+		p:       req.Params,
+		index:   req.Index,
+		peers:   req.Peers,
+		turn:    req.Turn,
+		startY:  self.StartY,
+		endY:    self.EndY,
+		rows:    req.Strip,
+		clients: make(map[string]*rpc.Client),
+	}
+	for _, y := range HaloRows(self.StartY, self.EndY, req.Params) {
+		i := strip.owner(y)
+		if i < 0 || i == req.Index {
+			continue
+		}
+		address := req.Peers[i].Address
+		if strip.clients[address] != nil {
+			continue
+		}
+		client, err := rpc.Dial("tcp", address)
+		if err != nil {
+			strip.close()
+			return err
+		}
+		strip.clients[address] = client
+	}
+	w.stripMutex.Lock()
+	if w.strip != nil {
+		w.strip.close()
+	}
+	w.strip = strip
+	w.stripMutex.Unlock()
+	return nil
+}
+
+// StepStrip fetches this strip's halo rows from its neighbours and advances it by one turn.
+func (w *Worker) StepStrip(req stubs.StepStripRequest, res *stubs.StepStripResponse) error {
+	strip := w.currentStrip()
+	if strip == nil {
+		return fmt.Errorf("no strip loaded")
+	}
+	strip.mutex.Lock()
+	turn := strip.turn
+	strip.mutex.Unlock()
+	if turn != req.Turn {
+		return fmt.Errorf("strip is at turn %d, broker expected %d", turn, req.Turn)
+	}
+	world := &util.Grid{Width: strip.p.ImageWidth, Height: strip.p.ImageHeight, Bits: GridBits(strip.p), Rows: make([][]byte, strip.p.ImageHeight)}
+	for y, row := range strip.rows {
+		world.Rows[strip.startY+y] = row
+	}
+	for _, y := range HaloRows(strip.startY, strip.endY, strip.p) {
+		row, err := strip.halo(y, turn)
+		if err != nil {
+			return err
+		}
+		world.Rows[y] = row
+	}
+	newRows, flipped := CalculateStrip(world, strip.startY, strip.endY, strip.p)
+	strip.mutex.Lock()
+	strip.previous = strip.rows
+	strip.rows = newRows
+	strip.turn++
+	strip.mutex.Unlock()
+	res.Flipped = flipped
+	return nil
+}
+
+// GetHalo returns row Y of this worker's strip as it was at the requested turn.
+func (w *Worker) GetHalo(req stubs.HaloRequest, res *stubs.HaloResponse) error {
+	strip := w.currentStrip()
+	if strip == nil {
+		return fmt.Errorf("no strip loaded")
+	}
+	strip.mutex.Lock()
+	defer strip.mutex.Unlock()
+	if req.Y < strip.startY || req.Y >= strip.endY {
+		return fmt.Errorf("row %d is outside strip [%d, %d)", req.Y, strip.startY, strip.endY)
+	}
+	switch req.Turn {
+	case strip.turn:
+		res.Row = strip.rows[req.Y-strip.startY]
+	case strip.turn - 1:
+		res.Row = strip.previous[req.Y-strip.startY]
+	default:
+		return fmt.Errorf("row %d requested for turn %d, strip is at turn %d", req.Y, req.Turn, strip.turn)
+	}
+	return nil
+}
+
+// GetStrip returns this worker's strip so the broker can assemble the world.
+func (w *Worker) GetStrip(req stubs.GetStripRequest, res *stubs.GetStripResponse) error {
+	strip := w.currentStrip()
+	if strip == nil {
+		return fmt.Errorf("no strip loaded")
+	}
+	strip.mutex.Lock()
+	defer strip.mutex.Unlock()
+	res.Turn = strip.turn
+	res.StartY = strip.startY
+	res.Strip = strip.rows
+	return nil
+}
+
+func (w *Worker) currentStrip() *stripState {
+	w.stripMutex.Lock()
+	defer w.stripMutex.Unlock()
+	return w.strip
+}
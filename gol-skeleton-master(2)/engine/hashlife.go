@@ -0,0 +1,303 @@
+package engine
+
+import (
+	"fmt"
+	"math/bits"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+const (
+	// maxNodes is how many quadtree nodes hashLifeBackend keeps before it starts its table
+	// afresh with only the nodes of the current world.
+	maxNodes = 1 << 21
+	// leapTime is how long a leap should take. Leaps that finish sooner double in length
+	// and slower ones halve, so pausing and snapshots stay responsive.
+	leapTime = 50 * time.Millisecond
+)
+
+// node is a canonical quadtree node: a square of 2^level cells a side. Level 0 nodes are
+// single cells. Equal squares share one node, so results can be memoised per node.
+type node struct {
+	nw, ne, sw, se *node
+	level          uint
+	population     int
+	// next is the centre of the node after 2^nextShift turns, once worked out.
+	next      *node
+	nextShift uint
+}
+
+type leapKey struct {
+	world *node
+	shift uint
+}
+
+// hashLifeBackend steps a square torus whose side is a power of two with HashLife, which
+// memoises the future of every distinct square it meets and so can advance the world by
+// 2^k turns at a time. It implements Leaper.
+type hashLifeBackend struct {
+	rule  Rule
+	nodes map[[4]*node]*node
+	dead  *node
+	alive *node
+	// world is the whole torus.
+	world *node
+	// leaps memoises torus leaps too long to take in a single advance.
+	leaps map[leapKey]*node
+	// shift is the length of the next leap, as a power of two.
+	shift uint
+}
+
+func newHashLifeBackend() *hashLifeBackend {
+	return &hashLifeBackend{}
+}
+
+// checkHashLife reports an error if p cannot be run with HashLife.
+func checkHashLife(p stubs.Params, rule Rule, topology Topology) error {
+	if rule.States > 2 {
+		return fmt.Errorf("the hashlife algorithm does not support Generations rules such as %v", rule)
+	}
+	if topology != Torus {
+		return fmt.Errorf("the hashlife algorithm only supports a torus, not %v", topology)
+	}
+	side := p.ImageWidth
+	if side != p.ImageHeight || side < 2 || side&(side-1) != 0 {
+		return fmt.Errorf("the hashlife algorithm needs a square world whose side is a power of two, not %dx%d",
+			p.ImageWidth, p.ImageHeight)
+	}
+	return nil
+}
+
+func (h *hashLifeBackend) Load(world *util.Grid, p stubs.Params) error {
+	h.rule = mustParseRule(p)
+	h.nodes = make(map[[4]*node]*node)
+	h.leaps = make(map[leapKey]*node)
+	h.dead = &node{}
+	h.alive = &node{population: 1}
+	h.shift = 0
+	level := uint(bits.TrailingZeros(uint(p.ImageWidth)))
+	if world == nil {
+		world = util.NewGrid(p.ImageWidth, p.ImageHeight)
+	}
+	h.world = h.build(world, 0, 0, level)
+	return nil
+}
+
+// build returns the node for the square of world with its top-left corner at (x, y).
+func (h *hashLifeBackend) build(world *util.Grid, x, y int, level uint) *node {
+	if level == 0 {
+		if world.Alive(x, y) {
+			return h.alive
+		}
+		return h.dead
+	}
+	half := 1 << (level - 1)
+	return h.join(
+		h.build(world, x, y, level-1), h.build(world, x+half, y, level-1),
+		h.build(world, x, y+half, level-1), h.build(world, x+half, y+half, level-1))
+}
+
+// join returns the canonical node made of four quadrants of the same level.
+func (h *hashLifeBackend) join(nw, ne, sw, se *node) *node {
+	key := [4]*node{nw, ne, sw, se}
+	if n, ok := h.nodes[key]; ok {
+		return n
+	}
+	n := &node{
+		nw: nw, ne: ne, sw: sw, se: se,
+		level:      nw.level + 1,
+		population: nw.population + ne.population + sw.population + se.population,
+	}
+	h.nodes[key] = n
+	return n
+}
+
+// centre returns the middle half of n.
+func (h *hashLifeBackend) centre(n *node) *node {
+	return h.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+// horizontal returns the middle half of the rectangle made by w and e side by side.
+func (h *hashLifeBackend) horizontal(w, e *node) *node {
+	return h.join(w.ne, e.nw, w.se, e.sw)
+}
+
+// vertical returns the middle half of the rectangle made by n above s.
+func (h *hashLifeBackend) vertical(n, s *node) *node {
+	return h.join(n.sw, n.se, s.nw, s.ne)
+}
+
+// advance returns the centre of n, a node of level 2 or more, after 2^shift turns, where
+// shift is at most n.level-2 so that no cell outside n can reach the centre in time.
+func (h *hashLifeBackend) advance(n *node, shift uint) *node {
+	if n.next != nil && n.nextShift == shift {
+		return n.next
+	}
+	var next *node
+	if n.level == 2 {
+		next = h.stepSmall(n)
+	} else {
+		// The nine overlapping squares of half n's size, row by row.
+		squares := [9]*node{
+			n.nw, h.horizontal(n.nw, n.ne), n.ne,
+			h.vertical(n.nw, n.sw), h.centre(n), h.vertical(n.ne, n.se),
+			n.sw, h.horizontal(n.sw, n.se), n.se,
+		}
+		// At full speed both halves of the leap are taken by advancing; otherwise the first
+		// takes all of it and the second only trims the result to size.
+		first := shift
+		if shift == n.level-2 {
+			first = shift - 1
+		}
+		var moved [9]*node
+		for i, square := range squares {
+			moved[i] = h.advance(square, first)
+		}
+		quarter := func(i int) *node {
+			q := h.join(moved[i], moved[i+1], moved[i+3], moved[i+4])
+			if shift == n.level-2 {
+				return h.advance(q, first)
+			}
+			return h.centre(q)
+		}
+		next = h.join(quarter(0), quarter(1), quarter(3), quarter(4))
+	}
+	n.next, n.nextShift = next, shift
+	return next
+}
+
+// stepSmall returns the centre 2x2 cells of a 4x4 node after one turn.
+func (h *hashLifeBackend) stepSmall(n *node) *node {
+	var cells [4][4]bool
+	for i, q := range [4]*node{n.nw, n.ne, n.sw, n.se} {
+		for j, c := range [4]*node{q.nw, q.ne, q.sw, q.se} {
+			cells[i/2*2+j/2][i%2*2+j%2] = c.population == 1
+		}
+	}
+	var result [4]*node
+	for i := range result {
+		y, x := 1+i/2, 1+i%2
+		alive := 0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if (dx != 0 || dy != 0) && cells[y+dy][x+dx] {
+					alive++
+				}
+			}
+		}
+		if cells[y][x] && h.rule.Survival[alive] || !cells[y][x] && h.rule.Birth[alive] {
+			result[i] = h.alive
+		} else {
+			result[i] = h.dead
+		}
+	}
+	return h.join(result[0], result[1], result[2], result[3])
+}
+
+// leap returns the torus world after 2^shift turns. Four copies of the world side by side
+// are advanced, which gives the torus shifted by half its side, so the quadrants of the
+// result are swapped back into place.
+func (h *hashLifeBackend) leap(world *node, shift uint) *node {
+	if shift >= world.level {
+		key := leapKey{world, shift}
+		if next, ok := h.leaps[key]; ok {
+			return next
+		}
+		next := h.leap(h.leap(world, shift-1), shift-1)
+		h.leaps[key] = next
+		return next
+	}
+	moved := h.advance(h.join(world, world, world, world), shift)
+	return h.join(moved.se, moved.sw, moved.ne, moved.nw)
+}
+
+// Step advances the world by one turn.
+func (h *hashLifeBackend) Step() ([]util.Cell, error) {
+	old := h.world
+	h.world = h.leap(h.world, 0)
+	h.collect()
+	return h.changed(old, h.world, 0, 0, nil), nil
+}
+
+// Leap advances the world by a power of two turns, at most turns, and returns how many.
+func (h *hashLifeBackend) Leap(turns int) (int, []util.Cell, error) {
+	shift := h.shift
+	if longest := uint(bits.Len(uint(turns))) - 1; shift > longest {
+		shift = longest
+	}
+	start := time.Now()
+	old := h.world
+	h.world = h.leap(h.world, shift)
+	if elapsed := time.Since(start); elapsed < leapTime && shift == h.shift {
+		h.shift++
+	} else if elapsed > 4*leapTime && h.shift > 0 {
+		h.shift--
+	}
+	h.collect()
+	return 1 << shift, h.changed(old, h.world, 0, 0, nil), nil
+}
+
+// changed appends the cells that differ between a and b, which lie at (x, y), to cells.
+// Equal squares are the same node, so unchanged parts of the world are skipped at once.
+func (h *hashLifeBackend) changed(a, b *node, x, y int, cells []util.Cell) []util.Cell {
+	if a == b {
+		return cells
+	}
+	if a.level == 0 {
+		return append(cells, util.Cell{X: x, Y: y})
+	}
+	half := 1 << (a.level - 1)
+	cells = h.changed(a.nw, b.nw, x, y, cells)
+	cells = h.changed(a.ne, b.ne, x+half, y, cells)
+	cells = h.changed(a.sw, b.sw, x, y+half, cells)
+	return h.changed(a.se, b.se, x+half, y+half, cells)
+}
+
+// collect starts the node table afresh once it holds maxNodes, keeping only the world.
+func (h *hashLifeBackend) collect() {
+	if len(h.nodes) < maxNodes {
+		return
+	}
+	old := h.world
+	h.nodes = make(map[[4]*node]*node)
+	h.leaps = make(map[leapKey]*node)
+	copies := make(map[*node]*node)
+	var copyNode func(n *node) *node
+	copyNode = func(n *node) *node {
+		if n.level == 0 {
+			return n
+		}
+		if c, ok := copies[n]; ok {
+			return c
+		}
+		c := h.join(copyNode(n.nw), copyNode(n.ne), copyNode(n.sw), copyNode(n.se))
+		copies[n] = c
+		return c
+	}
+	h.world = copyNode(old)
+}
+
+func (h *hashLifeBackend) World() (*util.Grid, error) {
+	side := 1 << h.world.level
+	world := util.NewGrid(side, side)
+	h.fill(world, h.world, 0, 0)
+	return world, nil
+}
+
+// fill sets the alive cells of n, which lies at (x, y), in world.
+func (h *hashLifeBackend) fill(world *util.Grid, n *node, x, y int) {
+	if n.population == 0 {
+		return
+	}
+	if n.level == 0 {
+		world.Set(x, y, true)
+		return
+	}
+	half := 1 << (n.level - 1)
+	h.fill(world, n.nw, x, y)
+	h.fill(world, n.ne, x+half, y)
+	h.fill(world, n.sw, x, y+half)
+	h.fill(world, n.se, x+half, y+half)
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/internal/fixture"
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestHashLife tests that leaping over the check/images fixtures gives the same worlds, and
+// the same flipped cells, as stepping them a turn at a time.
+func TestHashLife(t *testing.T) {
+	for _, size := range []int{16, 64, 512} {
+		start := fixture.World(t, size, 0)
+		for _, turns := range []int{1, 2, 7, 64, 100, 333} {
+			name := fmt.Sprintf("%dx%dx%d", size, size, turns)
+			p := stubs.Params{ImageWidth: size, ImageHeight: size, Turns: turns, Threads: 4, Algorithm: "hashlife"}
+			h := newHashLifeBackend()
+			if err := h.Load(start, p); err != nil {
+				t.Fatal(err)
+			}
+			stepped := start
+			for done := 0; done < turns; {
+				n, flipped, err := h.Leap(turns - done)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n < 1 || n > turns-done {
+					t.Fatalf("%s: leapt %d turns with %d left", name, n, turns-done)
+				}
+				before := stepped
+				for i := 0; i < n; i++ {
+					stepped, _ = CalculateNextState(stepped, p)
+				}
+				done += n
+				assertSameFlips(t, flipped, before, stepped, fmt.Sprintf("%s at turn %d", name, done))
+			}
+			world, err := h.World()
+			if err != nil {
+				t.Fatal(err)
+			}
+			fixture.AssertSameWorld(t, world, stepped, name)
+			if turns == 100 {
+				fixture.AssertSameWorld(t, world, fixture.World(t, size, turns), name)
+			}
+		}
+	}
+}
+
+// assertSameFlips fails the test unless flipped lists exactly the cells that differ
+// between before and after.
+func assertSameFlips(t *testing.T, flipped []util.Cell, before, after *util.Grid, name string) {
+	t.Helper()
+	listed := make(map[util.Cell]bool)
+	for _, cell := range flipped {
+		if listed[cell] {
+			t.Fatalf("%s: cell %v flipped twice", name, cell)
+		}
+		listed[cell] = true
+	}
+	for y := 0; y < before.Height; y++ {
+		for x := 0; x < before.Width; x++ {
+			cell := util.Cell{X: x, Y: y}
+			if changed := before.Alive(x, y) != after.Alive(x, y); changed != listed[cell] {
+				t.Fatalf("%s: cell %v changed is %v, but flipped is %v", name, cell, changed, listed[cell])
+			}
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Rule is a Life-like rule: Birth[n] is set if a dead cell with n alive neighbours comes to
+// life, and Survival[n] if an alive cell with n alive neighbours stays alive.
+//
+// Generations rules have more than two States: an alive cell that does not survive passes
+// through the dying states 2 to States-1, one per turn, before it is dead again. Dying cells
+// do not count as alive neighbours and cannot be born into.
+type Rule struct {
+	Birth    [9]bool
+	Survival [9]bool
+	States   int
+}
+
+// maxStates is the most states a cell can have, as states are saved as grey levels.
+const maxStates = 256
+
+// namedRules are the rules that may be given by name rather than in B/S notation.
+var namedRules = map[string]string{
+	"life":        "B3/S23",
+	"conway":      "B3/S23",
+	"highlife":    "B36/S23",
+	"seeds":       "B2/S",
+	"daynight":    "B3678/S34678",
+	"briansbrain": "B2/S/C3",
+	"starwars":    "B2/S345/C4",
+}
+
+// ParseRule parses a rule in B/S notation such as "B36/S23", or S/B notation such as
+// "23/36", or one of the names in namedRules. A Generations rule adds the number of states,
+// as in "B2/S345/C4" or "345/2/4". Case is ignored and the slashes are optional in B/S
+// notation, but a slash must be followed by another part. A rule under which no cell is
+// ever born or survives is rejected. An empty string is Conway's Life.
+func ParseRule(s string) (Rule, error) {
+	r := Rule{States: 2}
+	invalid := fmt.Errorf("invalid rule %q: want B/S notation like B3/S23, or B2/S/C3 for Generations", s)
+	text := strings.ToLower(strings.TrimSpace(s))
+	if text == "" {
+		text = "b3/s23"
+	}
+	if named, ok := namedRules[text]; ok {
+		text = strings.ToLower(named)
+	}
+	if !strings.ContainsAny(text, "bsc") {
+		parts := strings.Split(text, "/")
+		if len(parts) < 2 || len(parts) > 3 {
+			return r, invalid
+		}
+		text = "b" + parts[1] + "s" + parts[0]
+		if len(parts) == 3 {
+			text += "c" + parts[2]
+		}
+	}
+	var counts *[9]bool
+	states := ""
+	seen := ""
+	// slash is set after a slash, which must be followed by another part.
+	slash := false
+	for _, c := range text {
+		part := strings.ContainsRune("bsc", c) && !strings.ContainsRune(seen, c)
+		if slash && !part {
+			return r, invalid
+		}
+		slash = false
+		switch {
+		case part:
+			seen += string(c)
+			counts = nil
+			if c == 'b' {
+				counts = &r.Birth
+			} else if c == 's' {
+				counts = &r.Survival
+			}
+		case c == '/' && seen != "":
+			slash = true
+		case c >= '0' && c <= '9' && strings.HasSuffix(seen, "c"):
+			states += string(c)
+		case c >= '0' && c <= '8' && counts != nil:
+			counts[c-'0'] = true
+		default:
+			return r, invalid
+		}
+	}
+	if slash {
+		return r, invalid
+	}
+	if !strings.Contains(seen, "b") || !strings.Contains(seen, "s") {
+		return r, fmt.Errorf("invalid rule %q: want both a B and an S part", s)
+	}
+	if r.Birth == [9]bool{} && r.Survival == [9]bool{} {
+		return r, fmt.Errorf("invalid rule %q: no cell is ever born or survives", s)
+	}
+	if strings.Contains(seen, "c") {
+		n, err := strconv.Atoi(states)
+		if err != nil || n < 2 || n > maxStates {
+			return r, fmt.Errorf("invalid rule %q: the number of states must be from 2 to %d", s, maxStates)
+		}
+		r.States = n
+	}
+	return r, nil
+}
+
+// String returns r in B/S notation, with the number of states for Generations rules.
+func (r Rule) String() string {
+	var b strings.Builder
+	b.WriteString("B")
+	for n, birth := range r.Birth {
+		if birth {
+			fmt.Fprint(&b, n)
+		}
+	}
+	b.WriteString("/S")
+	for n, survival := range r.Survival {
+		if survival {
+			fmt.Fprint(&b, n)
+		}
+	}
+	if r.States > 2 {
+		fmt.Fprintf(&b, "/C%d", r.States)
+	}
+	return b.String()
+}
+
+// next returns the state after state for a cell with the given number of alive neighbours.
+func (r Rule) next(state uint8, alive int) uint8 {
+	switch state {
+	case 0:
+		if r.Birth[alive] {
+			return 1
+		}
+		return 0
+	case 1:
+		if r.Survival[alive] {
+			return 1
+		}
+	}
+	return r.Advance(state)
+}
+
+// Advance returns the state a cell moves to from state when it changes. It lets a
+// controller follow cell states from the list of cells changed by each turn.
+func (r Rule) Advance(state uint8) uint8 {
+	if state == 0 {
+		return 1
+	}
+	if int(state)+1 >= r.States {
+		return 0
+	}
+	return state + 1
+}
+
+// GridBits returns the bits per cell of worlds simulated with p, which must have been
+// checked with CheckParams.
+func GridBits(p stubs.Params) int {
+	return util.BitsFor(mustParseRule(p).States)
+}
+
+// CheckParams reports an error if p cannot be simulated.
+func CheckParams(p stubs.Params) error {
+	rule, err := ParseRule(p.Rule)
+	if err != nil {
+		return err
+	}
+	topology, err := ParseTopology(p.Topology)
+	if err != nil {
+		return err
+	}
+	return checkAlgorithm(p, rule, topology)
+}
+
+// mustParseRule parses p.Rule, which callers must already have checked with CheckParams.
+func mustParseRule(p stubs.Params) Rule {
+	rule, err := ParseRule(p.Rule)
+	if err != nil {
+		panic(err)
+	}
+	return rule
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// rule builds a Rule from the neighbour counts for birth and survival.
+func rule(birth, survival []int, states int) Rule {
+	r := Rule{States: states}
+	for _, n := range birth {
+		r.Birth[n] = true
+	}
+	for _, n := range survival {
+		r.Survival[n] = true
+	}
+	return r
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Rule
+		text  string
+	}{
+		{"", rule([]int{3}, []int{2, 3}, 2), "B3/S23"},
+		{"B3/S23", rule([]int{3}, []int{2, 3}, 2), "B3/S23"},
+		{"b3s23", rule([]int{3}, []int{2, 3}, 2), "B3/S23"},
+		{" S23/B3 ", rule([]int{3}, []int{2, 3}, 2), "B3/S23"},
+		{"23/3", rule([]int{3}, []int{2, 3}, 2), "B3/S23"},
+		{"B36/S23", rule([]int{3, 6}, []int{2, 3}, 2), "B36/S23"},
+		{"HighLife", rule([]int{3, 6}, []int{2, 3}, 2), "B36/S23"},
+		{"/3", rule([]int{3}, nil, 2), "B3/S"},
+		{"B2/S", rule([]int{2}, nil, 2), "B2/S"},
+		{"B/S012345678", rule(nil, []int{0, 1, 2, 3, 4, 5, 6, 7, 8}, 2), "B/S012345678"},
+		{"B2/S/C3", rule([]int{2}, nil, 3), "B2/S/C3"},
+		{"B2/S345/C4", rule([]int{2}, []int{3, 4, 5}, 4), "B2/S345/C4"},
+		{"345/2/4", rule([]int{2}, []int{3, 4, 5}, 4), "B2/S345/C4"},
+		{"B2/S/C256", rule([]int{2}, nil, 256), "B2/S/C256"},
+		{"B3/S23/C2", rule([]int{3}, []int{2, 3}, 2), "B3/S23"},
+	}
+	for _, test := range tests {
+		got, err := ParseRule(test.input)
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", test.input, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseRule(%q) = %v, want %v", test.input, got, test.want)
+		}
+		if got.String() != test.text {
+			t.Errorf("ParseRule(%q).String() = %q, want %q", test.input, got.String(), test.text)
+		}
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, input := range []string{
+		"/",
+		"B/S",
+		"bs",
+		"B/S/C3",
+		"life2",
+		"nonsense",
+		"3",
+		"B3",
+		"S23",
+		"B9/S23",
+		"B3/S29",
+		"B3/S23/B4",
+		"B3//S23",
+		"B3/S23/",
+		"/B3/S23",
+		"B3/23",
+		"1/2/3/4",
+		"B2/S/C",
+		"B2/S/C1",
+		"B2/S/C257",
+		"B2/S/C3/4",
+		"B2/S/Cx",
+		"B2/S/C-3",
+	} {
+		if got, err := ParseRule(input); err == nil {
+			t.Errorf("ParseRule(%q) = %v, want an error", input, got)
+		}
+	}
+}
+
+// TestGenerationsDecay tests that a cell that does not survive passes through each dying
+// state in turn, and that dying cells are not counted as alive neighbours.
+func TestGenerationsDecay(t *testing.T) {
+	p := stubs.Params{ImageWidth: 8, ImageHeight: 8, Threads: 1, Rule: "B3/S23/C4", Topology: "plane"}
+	world := util.NewStateGrid(8, 8, 4)
+	world.SetState(1, 1, 1)
+	for turn, want := range []uint8{2, 3, 0, 0} {
+		world, _ = CalculateNextState(world, p)
+		if state := world.State(1, 1); state != want {
+			t.Fatalf("lone cell at turn %d is in state %d, want %d", turn+1, state, want)
+		}
+	}
+
+	// (2, 2) has two alive neighbours and one dying, so is not born; with three alive it is.
+	for _, third := range []uint8{2, 1} {
+		world = util.NewStateGrid(8, 8, 4)
+		world.SetState(1, 1, 1)
+		world.SetState(2, 1, 1)
+		world.SetState(3, 1, third)
+		next, flipped := CalculateNextState(world, p)
+		var want uint8
+		if third == 1 {
+			want = 1
+		}
+		if got := next.State(2, 2); got != want {
+			t.Errorf("with (3, 1) in state %d, (2, 2) is in state %d, want %d", third, got, want)
+		}
+		if third == 2 && next.State(3, 1) != 3 {
+			t.Errorf("dying cell moved to state %d, want 3", next.State(3, 1))
+		}
+		for _, cell := range flipped {
+			if next.State(cell.X, cell.Y) == world.State(cell.X, cell.Y) {
+				t.Errorf("cell %v listed as changed but stayed in state %d", cell, world.State(cell.X, cell.Y))
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package engine
+
+import (
+	"math/bits"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// chunkSize is the width and height of a chunk; each chunk row is one uint64.
+const chunkSize = 64
+
+type chunkKey struct {
+	X, Y int
+}
+
+// chunk holds a chunkSize square of cells, bit x of row y being cell (x, y) of the chunk.
+type chunk [chunkSize]uint64
+
+// sparseBackend steps an unbounded universe, storing only the chunks that hold live cells.
+// The image is loaded with its top-left corner at the origin and patterns may then grow
+// in any direction; World returns the bounding box of the live cells.
+type sparseBackend struct {
+	chunks map[chunkKey]*chunk
+	rule   Rule
+}
+
+func newSparseBackend() *sparseBackend {
+	return &sparseBackend{chunks: make(map[chunkKey]*chunk)}
+}
+
+func (s *sparseBackend) Load(world *util.Grid, p stubs.Params) error {
+	s.chunks = make(map[chunkKey]*chunk)
+	s.rule = mustParseRule(p)
+	if world != nil {
+		for _, cell := range world.AliveCells() {
+			s.set(cell.X, cell.Y)
+		}
+	}
+	return nil
+}
+
+func (s *sparseBackend) set(x, y int) {
+	key := chunkKey{x >> 6, y >> 6}
+	c := s.chunks[key]
+	if c == nil {
+		c = new(chunk)
+		s.chunks[key] = c
+	}
+	c[y&(chunkSize-1)] |= 1 << uint(x&(chunkSize-1))
+}
+
+// Edit sets cells alive or dead in place. As the world is unbounded, cells may be anywhere.
+func (s *sparseBackend) Edit(cells []util.Cell, alive bool) ([]util.Cell, error) {
+	var changed []util.Cell
+	for _, cell := range cells {
+		c := s.chunks[chunkKey{cell.X >> 6, cell.Y >> 6}]
+		bit := uint64(1) << uint(cell.X&(chunkSize-1))
+		was := c != nil && c[cell.Y&(chunkSize-1)]&bit != 0
+		if was == alive {
+			continue
+		}
+		if alive {
+			s.set(cell.X, cell.Y)
+		} else {
+			c[cell.Y&(chunkSize-1)] &^= bit
+		}
+		changed = append(changed, cell)
+	}
+	return changed, nil
+}
+
+// neighbourhood is a chunk and the eight around it, indexed by [dy+1][dx+1].
+type neighbourhood [3][3]*chunk
+
+func (s *sparseBackend) neighbourhood(key chunkKey) *neighbourhood {
+	var n neighbourhood
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			n[dy+1][dx+1] = s.chunks[chunkKey{key.X + dx, key.Y + dy}]
+		}
+	}
+	return &n
+}
+
+// row returns row y of the chunk dx across from the middle one. y may be -1 or chunkSize
+// to reach into the chunks above and below.
+func (n *neighbourhood) row(dx, y int) uint64 {
+	dy := 1
+	if y < 0 {
+		dy, y = 0, y+chunkSize
+	} else if y >= chunkSize {
+		dy, y = 2, y-chunkSize
+	}
+	c := n[dy][dx+1]
+	if c == nil {
+		return 0
+	}
+	return c[y]
+}
+
+func (s *sparseBackend) Step() ([]util.Cell, error) {
+	// Any chunk next to a live one may have births in it.
+	candidates := make(map[chunkKey]bool)
+	for key := range s.chunks {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				candidates[chunkKey{key.X + dx, key.Y + dy}] = true
+			}
+		}
+	}
+	next := make(map[chunkKey]*chunk)
+	var flipped []util.Cell
+	for key := range candidates {
+		n := s.neighbourhood(key)
+		var c chunk
+		empty := true
+		for y := 0; y < chunkSize; y++ {
+			var count bitCount
+			for dy := -1; dy <= 1; dy++ {
+				// Shift the row so bit x holds the cell to the left of x, then to the right.
+				middle := n.row(0, y+dy)
+				count.add(middle<<1 | n.row(-1, y+dy)>>(chunkSize-1))
+				count.add(middle>>1 | n.row(1, y+dy)<<(chunkSize-1))
+				if dy != 0 {
+					count.add(middle)
+				}
+			}
+			alive := n.row(0, y)
+			c[y] = alive&count.match(s.rule.Survival) | ^alive&count.match(s.rule.Birth)
+			if c[y] != 0 {
+				empty = false
+			}
+			for changed := c[y] ^ alive; changed != 0; changed &= changed - 1 {
+				x := bits.TrailingZeros64(changed)
+				flipped = append(flipped, util.Cell{X: key.X*chunkSize + x, Y: key.Y*chunkSize + y})
+			}
+		}
+		if !empty {
+			next[key] = &c
+		}
+	}
+	s.chunks = next
+	return flipped, nil
+}
+
+// World returns the bounding box of the live cells, with its origin set to where it lies.
+func (s *sparseBackend) World() (*util.Grid, error) {
+	first := true
+	var minX, minY, maxX, maxY int
+	for key, c := range s.chunks {
+		for y, row := range c {
+			if row == 0 {
+				continue
+			}
+			left := key.X*chunkSize + bits.TrailingZeros64(row)
+			right := key.X*chunkSize + chunkSize - 1 - bits.LeadingZeros64(row)
+			top := key.Y*chunkSize + y
+			if first {
+				minX, maxX, minY, maxY = left, right, top, top
+				first = false
+			}
+			minX, maxX = min(minX, left), max(maxX, right)
+			minY, maxY = min(minY, top), max(maxY, top)
+		}
+	}
+	if first {
+		return util.NewGrid(0, 0), nil
+	}
+	world := util.NewGrid(maxX-minX+1, maxY-minY+1)
+	world.OriginX, world.OriginY = minX, minY
+	for key, c := range s.chunks {
+		for y, row := range c {
+			for ; row != 0; row &= row - 1 {
+				x := bits.TrailingZeros64(row)
+				world.Set(key.X*chunkSize+x-minX, key.Y*chunkSize+y-minY, true)
+			}
+		}
+	}
+	return world, nil
+}
+
+// bitCount counts up to eight neighbours for 64 cells at once, one bit of the count in each word.
+type bitCount [4]uint64
+
+// add adds one to the count of every cell whose bit is set in w.
+func (c *bitCount) add(w uint64) {
+	for i := range c {
+		carry := c[i] & w
+		c[i] ^= w
+		w = carry
+	}
+}
+
+// match returns the cells whose count n has counts[n] set.
+func (c *bitCount) match(counts [9]bool) uint64 {
+	var matched uint64
+	for n, ok := range counts {
+		if !ok {
+			continue
+		}
+		equal := ^uint64(0)
+		for i := range c {
+			if n&(1<<uint(i)) != 0 {
+				equal &= c[i]
+			} else {
+				equal &^= c[i]
+			}
+		}
+		matched |= equal
+	}
+	return matched
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
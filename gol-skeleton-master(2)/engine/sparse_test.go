@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/internal/fixture"
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// cellSet returns the alive cells of world, in world coordinates, moved by dx, dy.
+func cellSet(world *util.Grid, dx, dy int) map[util.Cell]bool {
+	cells := make(map[util.Cell]bool)
+	for _, cell := range world.AliveCells() {
+		cells[util.Cell{X: cell.X + dx, Y: cell.Y + dy}] = true
+	}
+	return cells
+}
+
+// assertSameCells fails the test unless given and expected hold the same cells.
+func assertSameCells(t *testing.T, given, expected map[util.Cell]bool, name string) {
+	t.Helper()
+	for cell := range expected {
+		if !given[cell] {
+			t.Fatalf("%s: cell %v missing", name, cell)
+		}
+	}
+	for cell := range given {
+		if !expected[cell] {
+			t.Fatalf("%s: cell %v not expected", name, cell)
+		}
+	}
+}
+
+// compareSparse steps start for turns turns with the sparse backend, and with the dense
+// stepper on a plane with start placed margin cells in from each edge, and fails the test
+// unless the live cells and the cells flipped each turn are the same. It also fails if
+// anything reaches the plane's edge, where the two would differ.
+func compareSparse(t *testing.T, start *util.Grid, turns, margin int, name string) {
+	t.Helper()
+	s := newSparseBackend()
+	if err := s.Load(start, stubs.Params{Algorithm: "sparse"}); err != nil {
+		t.Fatal(err)
+	}
+	size := func(n int) int { return n + 2*margin }
+	p := stubs.Params{ImageWidth: size(start.Width), ImageHeight: size(start.Height), Threads: 4, Topology: "plane"}
+	dense := util.NewGrid(p.ImageWidth, p.ImageHeight)
+	for _, cell := range start.AliveCells() {
+		dense.Set(cell.X+margin, cell.Y+margin, true)
+	}
+	for turn := 1; turn <= turns; turn++ {
+		flipped, err := s.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var denseFlipped []util.Cell
+		dense, denseFlipped = CalculateNextState(dense, p)
+		at := fmt.Sprintf("%s at turn %d", name, turn)
+		for _, cell := range dense.AliveCells() {
+			if cell.X == 0 || cell.Y == 0 || cell.X == p.ImageWidth-1 || cell.Y == p.ImageHeight-1 {
+				t.Fatalf("%s: cell %v reached the edge of the plane", at, cell)
+			}
+		}
+		sparseCells := make(map[util.Cell]bool)
+		for _, cell := range flipped {
+			if sparseCells[cell] {
+				t.Fatalf("%s: cell %v flipped twice", at, cell)
+			}
+			sparseCells[cell] = true
+		}
+		denseCells := make(map[util.Cell]bool)
+		for _, cell := range denseFlipped {
+			denseCells[util.Cell{X: cell.X - margin, Y: cell.Y - margin}] = true
+		}
+		assertSameCells(t, sparseCells, denseCells, at+" flipped")
+	}
+	world, err := s.World()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameCells(t, cellSet(world, 0, 0), cellSet(dense, -margin, -margin), name)
+}
+
+// TestSparse tests that the sparse backend steps the check/images fixtures as the dense
+// stepper does on a plane large enough to hold everything they grow into.
+func TestSparse(t *testing.T) {
+	for _, test := range []struct {
+		size, turns int
+	}{
+		{16, 100},
+		{64, 100},
+		{512, 20},
+	} {
+		compareSparse(t, fixture.World(t, test.size, 0), test.turns, test.turns+1,
+			fmt.Sprintf("%dx%dx%d", test.size, test.size, test.turns))
+	}
+}
+
+// TestSparseGrowth tests the sparse backend on an R-pentomino placed across the corner of
+// four chunks, which grows and sends gliders across many more in every direction,
+// including into chunks at negative coordinates.
+func TestSparseGrowth(t *testing.T) {
+	const turns = 400
+	start := util.NewGrid(chunkSize+2, chunkSize+2)
+	for _, cell := range []util.Cell{{X: 64, Y: 63}, {X: 65, Y: 63}, {X: 63, Y: 64}, {X: 64, Y: 64}, {X: 64, Y: 65}} {
+		start.Set(cell.X, cell.Y, true)
+	}
+	compareSparse(t, start, turns, turns/2, "R-pentomino")
+
+	s := newSparseBackend()
+	if err := s.Load(start, stubs.Params{Algorithm: "sparse"}); err != nil {
+		t.Fatal(err)
+	}
+	for turn := 0; turn < turns; turn++ {
+		if _, err := s.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	negative := false
+	for key := range s.chunks {
+		negative = negative || key.X < 0 || key.Y < 0
+	}
+	if len(s.chunks) < 9 || !negative {
+		t.Errorf("pattern spread over only %d chunks, negative %v", len(s.chunks), negative)
+	}
+}
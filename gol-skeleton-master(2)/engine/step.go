@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+type workerChannels struct {
+	worldSlice  chan [][]byte
+	flippedCell chan []util.Cell
+}
+
+func makeImmutableMatrix(world *util.Grid, bits int) func(y, x int) uint8 {
+	return func(y, x int) uint8 {
+		return util.RowState(world.Rows[y], x, bits)
+	}
+}
+
+// MakeNewWorld returns height packed rows of width dead cells, bits to a cell.
+func MakeNewWorld(height, width, bits int) [][]byte {
+	newWorld := make([][]byte, height)
+	for i := range newWorld {
+		newWorld[i] = util.NewRow(width, bits)
+	}
+	return newWorld
+}
+
+// calculateNewCellValue steps rows [Y1, Y2) and returns them with the cells that changed state.
+func calculateNewCellValue(Y1, Y2, X1, X2 int, data func(y, x int) uint8, rule Rule, topology Topology, p stubs.Params) ([][]byte, []util.Cell) {
+	height := Y2 - Y1
+	bits := util.BitsFor(rule.States)
+	nextSLice := MakeNewWorld(height, p.ImageWidth, bits)
+	var Cell []util.Cell
+	for i := Y1; i < Y2; i++ {
+		for j := X1; j < X2; j++ {
+			alive := 0
+			for _, a := range [3]int{j - 1, j, j + 1} {
+				for _, q := range [3]int{i - 1, i, i + 1} {
+					if a >= 0 && a < p.ImageWidth && q >= 0 && q < p.ImageHeight {
+						if data(q, a) == 1 {
+							alive++
+						}
+					} else if newL, newK, ok := topology.wrap(a, q, p.ImageWidth, p.ImageHeight); ok && data(newK, newL) == 1 {
+						alive++
+					}
+				}
+			}
+			state := data(i, j)
+			if state == 1 {
+				alive -= 1
+			}
+			next := rule.next(state, alive)
+			if next != 0 {
+				util.SetRowState(nextSLice[i-Y1], j, bits, next)
+			}
+			if next != state {
+				cell := util.Cell{X: j, Y: i}
+				Cell = append(Cell, cell)
+			}
+		}
+	}
+	return nextSLice, Cell
+}
+
+func worker(Y1, Y2, X1, X2 int, data func(y, x int) uint8, rule Rule, topology Topology, out workerChannels, p stubs.Params) {
+	work, workCell := calculateNewCellValue(Y1, Y2, X1, X2, data, rule, topology, p)
+	out.worldSlice <- work
+	out.flippedCell <- workCell
+}
+
+func CalculateNextState(world *util.Grid, p stubs.Params) (*util.Grid, []util.Cell) {
+	rows, flipped := CalculateStrip(world, 0, p.ImageHeight, p)
+	return &util.Grid{Width: p.ImageWidth, Height: p.ImageHeight, Bits: GridBits(p), Rows: rows}, flipped
+}
+
+// CalculateStrip computes the next state of rows [Y1, Y2) using p.Threads goroutines.
+// Only those rows and their neighbours need to be present in world; the rest may be nil.
+// p must have been checked with CheckParams.
+func CalculateStrip(world *util.Grid, Y1, Y2 int, p stubs.Params) ([][]byte, []util.Cell) {
+	rule := mustParseRule(p)
+	topology := mustParseTopology(p)
+	data := makeImmutableMatrix(world, util.BitsFor(rule.States))
+	height := Y2 - Y1
+	var newPixelData [][]byte
+	var flipped []util.Cell
+	if p.Threads <= 1 {
+		newPixelData, flipped = calculateNewCellValue(Y1, Y2, 0, p.ImageWidth, data, rule, topology, p)
+	} else {
+		ChanSlice := make([]workerChannels, p.Threads)
+
+		for i := 0; i < p.Threads; i++ {
+			ChanSlice[i].worldSlice = make(chan [][]byte)
+			ChanSlice[i].flippedCell = make(chan []util.Cell)
+		}
+		for i := 0; i < p.Threads-1; i++ {
+			go worker(Y1+int(float32(height)*(float32(i)/float32(p.Threads))),
+				Y1+int(float32(height)*(float32(i+1)/float32(p.Threads))),
+				0, p.ImageWidth, data, rule, topology, ChanSlice[i], p)
+		}
+		go worker(Y1+int(float32(height)*(float32(p.Threads-1)/float32(p.Threads))),
+			Y2,
+			0, p.ImageWidth, data, rule, topology, ChanSlice[p.Threads-1], p)
+
+		for i := 0; i < p.Threads; i++ {
+
+			part := <-ChanSlice[i].worldSlice
+			newPixelData = append(newPixelData, part...)
+
+			flippedPart := <-ChanSlice[i].flippedCell
+			flipped = append(flipped, flippedPart...)
+		}
+	}
+	return newPixelData, flipped
+}
+
+// HaloRows returns the rows outside [Y1, Y2) that CalculateStrip reads: usually the rows
+// either side of the strip, but on a cross-surface also the rows mirroring it.
+func HaloRows(Y1, Y2 int, p stubs.Params) []int {
+	topology := mustParseTopology(p)
+	var rows []int
+	seen := make(map[int]bool)
+	for y := Y1 - 1; y <= Y2; y++ {
+		// Which row a neighbour is in only depends on whether it is beyond the left or right edge.
+		for _, x := range [3]int{-1, 0, p.ImageWidth} {
+			_, row, ok := topology.wrap(x, y, p.ImageWidth, p.ImageHeight)
+			if ok && (row < Y1 || row >= Y2) && !seen[row] {
+				seen[row] = true
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+// StripBounds returns the rows [Y1, Y2) of strip i when the world is split into n strips.
+func StripBounds(i, n int, p stubs.Params) (int, int) {
+	return p.ImageHeight * i / n, p.ImageHeight * (i + 1) / n
+}
+
+func calculateAliveCells(p stubs.Params, world *util.Grid) []util.Cell {
+	if world == nil {
+		return nil
+	}
+	return world.AliveCells()
+}
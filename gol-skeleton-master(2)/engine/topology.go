@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// Topology says how the edges of the world are joined up.
+type Topology int
+
+const (
+	// Torus joins each edge to the opposite one.
+	Torus Topology = iota
+	// Plane has no wrapping: cells beyond the edges are always dead.
+	Plane
+	// KleinBottle joins the left and right edges like a torus, and the top and bottom edges
+	// with a twist, so a glider leaving through the top comes back mirrored left to right.
+	KleinBottle
+	// CrossSurface joins both pairs of edges with a twist. Cells diagonally across a corner
+	// are not neighbours, as the corners of a cross-surface meet in a single point.
+	CrossSurface
+)
+
+var topologyNames = map[string]Topology{
+	"torus":        Torus,
+	"plane":        Plane,
+	"bounded":      Plane,
+	"klein":        KleinBottle,
+	"kleinbottle":  KleinBottle,
+	"cross":        CrossSurface,
+	"crosssurface": CrossSurface,
+}
+
+// ParseTopology parses the name of a topology, ignoring case, spaces and hyphens.
+// An empty string is a torus, the original behaviour.
+func ParseTopology(s string) (Topology, error) {
+	name := strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(s))
+	if name == "" {
+		return Torus, nil
+	}
+	if t, ok := topologyNames[name]; ok {
+		return t, nil
+	}
+	return Torus, fmt.Errorf("invalid topology %q: want torus, plane, klein or cross", s)
+}
+
+func (t Topology) String() string {
+	switch t {
+	case Torus:
+		return "torus"
+	case Plane:
+		return "plane"
+	case KleinBottle:
+		return "klein"
+	case CrossSurface:
+		return "cross"
+	default:
+		return fmt.Sprintf("Topology(%d)", int(t))
+	}
+}
+
+// wrap maps cell (x, y), which may lie one cell beyond an edge of a width by height world,
+// to the cell it stands for. ok is false if there is no such cell.
+func (t Topology) wrap(x, y, width, height int) (int, int, bool) {
+	outX := x < 0 || x >= width
+	outY := y < 0 || y >= height
+	switch t {
+	case Plane:
+		if outX || outY {
+			return 0, 0, false
+		}
+	case KleinBottle:
+		if outY {
+			x = width - 1 - x
+		}
+	case CrossSurface:
+		if outX && outY {
+			return 0, 0, false
+		}
+		if outX {
+			y = height - 1 - y
+		}
+		if outY {
+			x = width - 1 - x
+		}
+	}
+	return (x + width) % width, (y + height) % height, true
+}
+
+// mustParseTopology parses p.Topology, which callers must already have checked with CheckParams.
+func mustParseTopology(p stubs.Params) Topology {
+	t, err := ParseTopology(p.Topology)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/internal/fixture"
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+func TestParseTopology(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Topology
+	}{
+		{"", Torus},
+		{"torus", Torus},
+		{"Plane", Plane},
+		{"bounded", Plane},
+		{"klein", KleinBottle},
+		{"Klein Bottle", KleinBottle},
+		{"klein-bottle", KleinBottle},
+		{"cross", CrossSurface},
+		{"cross_surface", CrossSurface},
+	}
+	for _, test := range tests {
+		got, err := ParseTopology(test.input)
+		if err != nil || got != test.want {
+			t.Errorf("ParseTopology(%q) = %v, %v, want %v", test.input, got, err, test.want)
+		}
+		if again, err := ParseTopology(got.String()); err != nil || again != got {
+			t.Errorf("ParseTopology(%q) = %v, %v, want %v", got.String(), again, err, got)
+		}
+	}
+	for _, input := range []string{"sphere", "tor us x", "Topology(7)"} {
+		if got, err := ParseTopology(input); err == nil {
+			t.Errorf("ParseTopology(%q) = %v, want an error", input, got)
+		}
+	}
+}
+
+// neighbourCounts returns the number of alive neighbours of every cell of world under
+// topology, found by stepping it under each rule Bn/Sn in turn and seeing which cells are
+// then alive.
+func neighbourCounts(world *util.Grid, topology Topology) [][]int {
+	counts := make([][]int, world.Height)
+	for y := range counts {
+		counts[y] = make([]int, world.Width)
+		for x := range counts[y] {
+			counts[y][x] = -1
+		}
+	}
+	for n := 0; n <= 8; n++ {
+		p := stubs.Params{ImageWidth: world.Width, ImageHeight: world.Height, Threads: 1,
+			Rule: fmt.Sprintf("B%d/S%d", n, n), Topology: topology.String()}
+		next, _ := CalculateNextState(world, p)
+		for y := 0; y < world.Height; y++ {
+			for x := 0; x < world.Width; x++ {
+				if next.Alive(x, y) {
+					counts[y][x] = n
+				}
+			}
+		}
+	}
+	return counts
+}
+
+// TestTopologyNeighbours tests the number of neighbours of the corner, edge and inner
+// cells of a world that is all alive.
+func TestTopologyNeighbours(t *testing.T) {
+	const width, height = 5, 4
+	world := util.NewGrid(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			world.Set(x, y, true)
+		}
+	}
+	tests := []struct {
+		topology            Topology
+		corner, edge, inner int
+	}{
+		{Torus, 8, 8, 8},
+		{Plane, 3, 5, 8},
+		{KleinBottle, 8, 8, 8},
+		// The cell diagonally across each corner of a cross-surface is missing.
+		{CrossSurface, 7, 8, 8},
+	}
+	for _, test := range tests {
+		counts := neighbourCounts(world, test.topology)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				edges := 0
+				if x == 0 || x == width-1 {
+					edges++
+				}
+				if y == 0 || y == height-1 {
+					edges++
+				}
+				want := []int{test.inner, test.edge, test.corner}[edges]
+				if counts[y][x] != want {
+					t.Errorf("%v: cell (%d, %d) has %d neighbours, want %d", test.topology, x, y, counts[y][x], want)
+				}
+			}
+		}
+	}
+}
+
+// TestTopologyWrap tests which cells see a single alive cell in the top-left corner, or
+// beside it on the top edge, as a neighbour.
+func TestTopologyWrap(t *testing.T) {
+	const width, height = 5, 4
+	tests := []struct {
+		topology Topology
+		alive    util.Cell
+		// seen lists the cells with the alive cell as a neighbour, besides those next to
+		// it within the world.
+		seen []util.Cell
+	}{
+		{Torus, util.Cell{X: 0, Y: 0}, []util.Cell{{X: 4, Y: 0}, {X: 4, Y: 1}, {X: 0, Y: 3}, {X: 1, Y: 3}, {X: 4, Y: 3}}},
+		{Plane, util.Cell{X: 0, Y: 0}, nil},
+		// The top edge joins the bottom mirrored, and the left edge the right as it is.
+		{KleinBottle, util.Cell{X: 0, Y: 0}, []util.Cell{{X: 4, Y: 0}, {X: 4, Y: 1}, {X: 4, Y: 3}, {X: 3, Y: 3}, {X: 0, Y: 3}}},
+		{KleinBottle, util.Cell{X: 1, Y: 0}, []util.Cell{{X: 4, Y: 3}, {X: 3, Y: 3}, {X: 2, Y: 3}}},
+		// Both pairs of edges join mirrored, and the corner has no diagonal neighbour.
+		{CrossSurface, util.Cell{X: 0, Y: 0}, []util.Cell{{X: 4, Y: 3}, {X: 4, Y: 2}, {X: 4, Y: 3}, {X: 3, Y: 3}}},
+		{CrossSurface, util.Cell{X: 1, Y: 0}, []util.Cell{{X: 4, Y: 3}, {X: 3, Y: 3}, {X: 2, Y: 3}}},
+	}
+	for _, test := range tests {
+		world := util.NewGrid(width, height)
+		world.Set(test.alive.X, test.alive.Y, true)
+		want := make(map[util.Cell]int)
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				x, y := test.alive.X+dx, test.alive.Y+dy
+				if (dx != 0 || dy != 0) && x >= 0 && x < width && y >= 0 && y < height {
+					want[util.Cell{X: x, Y: y}]++
+				}
+			}
+		}
+		for _, cell := range test.seen {
+			want[cell]++
+		}
+		counts := neighbourCounts(world, test.topology)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if got := counts[y][x]; got != want[util.Cell{X: x, Y: y}] {
+					t.Errorf("%v with %v alive: cell (%d, %d) has %d neighbours, want %d",
+						test.topology, test.alive, x, y, got, want[util.Cell{X: x, Y: y}])
+				}
+			}
+		}
+	}
+}
+
+// TestKleinGlider tests that a glider leaving through the top of a Klein bottle comes back
+// through the bottom mirrored left to right, so it then heads up and to the left, while on
+// a torus it comes back as it left.
+func TestKleinGlider(t *testing.T) {
+	const size = 16
+	// A glider heading up and to the right, near the top of the world.
+	glider := []util.Cell{{X: 6, Y: 1}, {X: 7, Y: 2}, {X: 5, Y: 3}, {X: 6, Y: 3}, {X: 7, Y: 3}}
+	for y := range glider {
+		glider[y].Y = 4 - glider[y].Y
+	}
+	run := func(topology Topology, turns int) *util.Grid {
+		world := util.NewGrid(size, size)
+		for _, cell := range glider {
+			world.Set(cell.X, cell.Y, true)
+		}
+		p := stubs.Params{ImageWidth: size, ImageHeight: size, Threads: 2, Topology: topology.String()}
+		for turn := 0; turn < turns; turn++ {
+			world, _ = CalculateNextState(world, p)
+		}
+		return world
+	}
+	mirror := func(world *util.Grid) *util.Grid {
+		mirrored := util.NewGrid(world.Width, world.Height)
+		for y := 0; y < world.Height; y++ {
+			for x := 0; x < world.Width; x++ {
+				mirrored.Set(world.Width-1-x, y, world.Alive(x, y))
+			}
+		}
+		return mirrored
+	}
+	// Before it reaches the top edge, both worlds are the same.
+	fixture.AssertSameWorld(t, run(KleinBottle, 4), run(Torus, 4), "klein at turn 4")
+	// Once it has crossed, the Klein bottle holds the torus's glider mirrored.
+	for _, turns := range []int{32, 48} {
+		klein, torus := run(KleinBottle, turns), run(Torus, turns)
+		if klein.Count() != 5 {
+			t.Fatalf("klein at turn %d has %d alive cells, want a glider of 5", turns, klein.Count())
+		}
+		fixture.AssertSameWorld(t, klein, mirror(torus), fmt.Sprintf("klein at turn %d", turns))
+	}
+}
@@ -0,0 +1,544 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Worker runs a whole Game of Life simulation. The worker command registers it as an RPC
+// service, and gol uses it directly when running in-process.
+//
+// A simulation is a session that outlives the controller which started it: pressing q only
+// detaches that controller, and a later one can Attach and Wait on the same session.
+type Worker struct {
+	// backend holds the current session's world; it is fixed unless the session asks for
+	// the sparse or hashlife algorithm.
+	backend     Backend
+	fixed       Backend
+	currentTurn int
+	Param       stubs.Params
+	mutex       *sync.Mutex
+	paused      bool
+	exitChan    chan bool
+	killed      chan bool
+	killOnce    *sync.Once
+	strip       *stripState
+	stripMutex  *sync.Mutex
+
+	// session identifies the current simulation; it is empty when there is none.
+	session string
+	// stop ends the current session's run loop, which closes finished when it returns.
+	stop     chan bool
+	finished chan bool
+	runErr   error
+	// detached is closed by q to release the controller waiting on the session.
+	detached chan bool
+	// stream holds the turns not yet fetched by the controller with GetFlips.
+	stream *flipStream
+	// edits counts the edits made to the current session.
+	edits int
+	// limit is the most turns a second the session may run, or 0 for no limit, and until
+	// the turn it pauses itself at, or 0. lastStep is when it last stepped.
+	limit    float64
+	until    int
+	lastStep time.Time
+	// wake tells the run loop that paused has changed or a step may have completed the
+	// session.
+	wake chan bool
+
+	checkpoints *Checkpointer
+	// restored is a checkpoint loaded at start-up, resumed by the next matching GameOfLife call.
+	restored *checkpoint
+}
+
+var errNoSession = errors.New("no simulation is running")
+
+// NewWorker returns an idle Worker that steps the world in this process.
+func NewWorker() *Worker {
+	return NewWorkerWithBackend(&localBackend{})
+}
+
+// NewWorkerWithBackend returns an idle Worker that keeps its world in backend.
+func NewWorkerWithBackend(backend Backend) *Worker {
+	return &Worker{
+		backend:     backend,
+		fixed:       backend,
+		Param:       stubs.Params{},
+		currentTurn: 0,
+		mutex:       &sync.Mutex{},
+		paused:      false,
+		exitChan:    make(chan bool),
+		killed:      make(chan bool),
+		killOnce:    &sync.Once{},
+		stripMutex:  &sync.Mutex{},
+		wake:        make(chan bool, 1),
+	}
+}
+
+// EnableCheckpoints makes the Worker save its world with c as it runs, and when k is pressed.
+func (w *Worker) EnableCheckpoints(c *Checkpointer) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.checkpoints = c
+}
+
+// Restore loads the newest checkpoint in dir. The next GameOfLife call with the same
+// parameters carries on from it instead of from turn 0. It returns the restored turn.
+func (w *Worker) Restore(dir string) (int, error) {
+	saved, err := loadLatestCheckpoint(dir)
+	if err != nil {
+		return 0, err
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.restored = saved
+	if w.checkpoints != nil {
+		w.checkpoints.seed(saved.Turn)
+	}
+	return saved.Turn, nil
+}
+
+// Killed is closed once a controller has pressed k, so the hosting process can shut down.
+func (w *Worker) Killed() <-chan bool {
+	return w.killed
+}
+
+// GameOfLife starts a new session, replacing any detached one, and waits for it like Wait.
+// The turns it runs are not streamed; a controller that wants them calls Start instead.
+func (w *Worker) GameOfLife(req stubs.GameOfLifeRequest, res *stubs.GameOfLifeResponse) error {
+	session, _, err := w.start(req, false)
+	if err != nil {
+		return err
+	}
+	return w.Wait(stubs.WaitRequest{Session: session}, res)
+}
+
+// Start starts a new session like GameOfLife, but returns at once so the controller can
+// fetch each turn with GetFlips while it Waits. If the session resumes from a checkpoint,
+// res.World holds the world it resumes from.
+func (w *Worker) Start(req stubs.GameOfLifeRequest, res *stubs.StartResponse) error {
+	session, resumed, err := w.start(req, true)
+	if err != nil {
+		return err
+	}
+	res.Session = session
+	if resumed != nil {
+		res.Turn = resumed.Turn
+		res.World = resumed.World
+	}
+	return nil
+}
+
+// start begins a session and returns its name, and the checkpoint it resumed from if any.
+func (w *Worker) start(req stubs.GameOfLifeRequest, stream bool) (string, *checkpoint, error) {
+	if err := CheckParams(req.Params); err != nil {
+		return "", nil, err
+	}
+	if err := w.checkAlgorithm(req.Params); err != nil {
+		return "", nil, err
+	}
+	if req.World != nil && (req.World.CellBits() != GridBits(req.Params) || len(req.World.Rows) != req.Params.ImageHeight) {
+		return "", nil, fmt.Errorf("world does not match the parameters: %d rows of %d bits per cell, want %d rows of %d",
+			len(req.World.Rows), req.World.CellBits(), req.Params.ImageHeight, GridBits(req.Params))
+	}
+	w.mutex.Lock()
+	if w.stop != nil {
+		log.Printf("Session %s replaced at turn %d", w.session, w.currentTurn)
+		close(w.stop)
+		w.mutex.Unlock()
+		<-w.finished
+		w.mutex.Lock()
+	}
+	world, turn := req.World, 0
+	var resumed *checkpoint
+	if w.restored != nil && sameSimulation(req.Params, w.restored.Params) {
+		log.Printf("Resuming from checkpoint at turn %d", w.restored.Turn)
+		world, turn = w.restored.World, w.restored.Turn
+		resumed = w.restored
+		w.restored = nil
+	}
+	w.Param = req.Params
+	w.backend = w.fixed
+	switch req.Params.Algorithm {
+	case "sparse":
+		w.backend = newSparseBackend()
+	case "hashlife":
+		w.backend = newHashLifeBackend()
+	}
+	err := w.backend.Load(world, req.Params)
+	if err != nil {
+		w.session = ""
+		w.stop = nil
+		w.mutex.Unlock()
+		return "", nil, err
+	}
+	w.currentTurn = turn
+	if w.checkpoints != nil {
+		w.checkpoints.seed(turn)
+	}
+	w.paused = false
+	w.edits = 0
+	w.limit = 0
+	w.until = 0
+	w.runErr = nil
+	w.session = strconv.FormatInt(time.Now().UnixNano(), 36)
+	w.stop = make(chan bool)
+	w.finished = make(chan bool)
+	w.startStream(stream)
+	session := w.session
+	go w.run(session, req.Params.Turns, w.stop, w.finished)
+	w.mutex.Unlock()
+	log.Printf("Session %s started", session)
+	return session, resumed, nil
+}
+
+// checkAlgorithm reports an error if p asks for an algorithm that would replace a backend
+// the Worker was given, such as the broker's, which steps the world on other workers.
+func (w *Worker) checkAlgorithm(p stubs.Params) error {
+	if _, local := w.fixed.(*localBackend); local || p.Algorithm == "" {
+		return nil
+	}
+	return fmt.Errorf("the %s algorithm steps the whole world in one process, so cannot be spread over workers", p.Algorithm)
+}
+
+// startStream ends any previous stream and, if stream is set, records the current
+// session's turns from now on. The caller must hold the mutex.
+func (w *Worker) startStream(stream bool) {
+	if w.stream != nil {
+		w.stream.end()
+		w.stream = nil
+	}
+	if stream {
+		w.stream = newFlipStream(w.session)
+	}
+}
+
+// Stop ends the current session, if there is one.
+func (w *Worker) Stop() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+		w.session = ""
+	}
+}
+
+// run steps the world until turns have been completed, or until stop or k ends it early.
+// While a controller is more than flipBuffer turns behind it waits for it to catch up, and
+// while the speed is limited it waits between turns. Once it reaches the turn to run
+// until, it pauses. Whether it is paused is read from w.paused each time round, so p and
+// reaching that turn are seen the same way.
+func (w *Worker) run(session string, turns int, stop <-chan bool, finished chan<- bool) {
+	defer close(finished)
+	defer func() {
+		w.mutex.Lock()
+		w.endStream(session)
+		w.mutex.Unlock()
+	}()
+	for {
+		w.mutex.Lock()
+		turn, paused := w.currentTurn, w.paused
+		w.mutex.Unlock()
+		if turn >= turns {
+			return
+		}
+		if paused {
+			select {
+			case <-w.wake:
+			case <-stop:
+				return
+			case <-w.exitChan:
+				return
+			}
+			continue
+		}
+		if backlog, stream := w.backlog(); backlog != nil {
+			select {
+			case <-w.wake:
+			case <-backlog:
+			case <-time.After(flipStall):
+				w.stall(stream)
+			case <-stop:
+				return
+			case <-w.exitChan:
+				return
+			}
+			continue
+		}
+		if wait := w.pace(); wait > 0 {
+			select {
+			case <-w.wake:
+			case <-time.After(wait):
+			case <-stop:
+				return
+			case <-w.exitChan:
+				return
+			}
+			continue
+		}
+		select {
+		case <-stop:
+			return
+		case <-w.exitChan:
+			return
+		default:
+			w.mutex.Lock()
+			if w.paused {
+				// Paused since the loop last looked.
+				w.mutex.Unlock()
+				continue
+			}
+			world, _, err := w.step(turns - w.currentTurn)
+			if err != nil {
+				w.runErr = err
+			}
+			turn := w.currentTurn
+			w.mutex.Unlock()
+			if err != nil {
+				return
+			}
+			if world != nil {
+				w.saveCheckpoint(world, turn)
+			}
+		}
+	}
+}
+
+// wakeRun tells the run loop that the session's paused state or turn has changed, so it
+// looks at them again rather than carrying on waiting.
+func (w *Worker) wakeRun() {
+	select {
+	case w.wake <- true:
+	default:
+	}
+}
+
+// advance steps the world by one turn, or by up to turns turns if the backend can leap.
+// The caller must hold the mutex.
+func (w *Worker) advance(turns int) (int, []util.Cell, error) {
+	if leaper, ok := w.backend.(Leaper); ok {
+		return leaper.Leap(turns)
+	}
+	flipped, err := w.backend.Step()
+	return 1, flipped, err
+}
+
+// saveCheckpoint writes world to disk, logging rather than failing the session on errors.
+func (w *Worker) saveCheckpoint(world *util.Grid, turn int) {
+	if err := w.checkpoints.save(world, turn, w.Param); err != nil {
+		log.Printf("Checkpoint at turn %d failed: %v", turn, err)
+	}
+}
+
+func (w *Worker) turn() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.currentTurn
+}
+
+// Attach finds a session for a new controller: the one named in the request, or if no name
+// is given, one started with the same parameters apart from the thread count. Its turns are
+// streamed to the new controller from the one returned.
+func (w *Worker) Attach(req stubs.AttachRequest, res *stubs.AttachResponse) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.session == "" {
+		return nil
+	}
+	if req.Session != "" && req.Session != w.session {
+		return nil
+	}
+	if req.Session == "" && !sameSimulation(req.Params, w.Param) {
+		return nil
+	}
+	world, err := w.backend.World()
+	if err != nil {
+		return err
+	}
+	w.startStream(true)
+	res.Found = true
+	res.Session = w.session
+	res.Params = w.Param
+	res.Turn = w.currentTurn
+	res.Paused = w.paused
+	res.World = world
+	res.TurnsPerSecond = w.limit
+	res.Until = w.until
+	log.Printf("Controller attached to session %s at turn %d", w.session, w.currentTurn)
+	return nil
+}
+
+// sameSimulation reports whether a and b describe the same simulation; the thread count
+// only affects speed, so a controller may reattach with a different one.
+func sameSimulation(a, b stubs.Params) bool {
+	a.Threads = b.Threads
+	return a == b
+}
+
+// Wait blocks until the session finishes or the controller detaches by pressing q, then
+// fills res with the world at that point. A finished session is discarded once reported.
+func (w *Worker) Wait(req stubs.WaitRequest, res *stubs.GameOfLifeResponse) error {
+	w.mutex.Lock()
+	if w.session == "" || req.Session != w.session {
+		w.mutex.Unlock()
+		return errNoSession
+	}
+	finished := w.finished
+	detached := make(chan bool)
+	w.detached = detached
+	w.mutex.Unlock()
+
+	select {
+	case <-finished:
+	case <-detached:
+		res.Detached = true
+	}
+	res.Session = req.Session
+	if err := w.finish(res); err != nil {
+		return err
+	}
+	if !res.Detached {
+		w.mutex.Lock()
+		if w.session == req.Session {
+			w.session = ""
+			w.stop = nil
+		}
+		w.mutex.Unlock()
+	}
+	return nil
+}
+
+// finish fills res with the current world.
+func (w *Worker) finish(res *stubs.GameOfLifeResponse) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.runErr != nil {
+		return w.runErr
+	}
+	world, err := w.backend.World()
+	if err != nil {
+		return err
+	}
+	res.World = world
+	res.Turns = w.currentTurn
+	res.AliveCells = calculateAliveCells(w.Param, world)
+	return nil
+}
+
+func (w *Worker) GetAliveCells(req stubs.GetAliveCellsRequest, res *stubs.GetAliveCellsResponse) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	world, err := w.backend.World()
+	if err != nil {
+		return err
+	}
+	res.Turn = w.currentTurn
+	res.AliveCellsCount = world.Count()
+	return nil
+}
+
+func (w *Worker) KeyPress(req stubs.KeyPressRequest, res *stubs.KeyPressResponse) error {
+	w.mutex.Lock()
+	finished := w.finished
+	w.mutex.Unlock()
+	if finished == nil {
+		return errNoSession
+	}
+	switch req.Key {
+	case 'p':
+		w.mutex.Lock()
+		select {
+		case <-finished:
+		default:
+			w.paused = !w.paused
+			if w.paused {
+				log.Printf("Turn %d paused", w.currentTurn)
+			} else {
+				log.Printf("Turn %d resume", w.currentTurn)
+			}
+		}
+		res.Paused = w.paused
+		res.Turn = w.currentTurn
+		w.mutex.Unlock()
+		w.wakeRun()
+	case 'q':
+		w.mutex.Lock()
+		res.Turn = w.currentTurn
+		if w.detached != nil {
+			close(w.detached)
+			w.detached = nil
+		}
+		w.endStream(w.session)
+		log.Printf("Controller detached from session %s at turn %d", w.session, w.currentTurn)
+		w.mutex.Unlock()
+	case 's':
+		w.mutex.Lock()
+		world, err := w.backend.World()
+		res.Turn = w.currentTurn
+		w.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+		res.World = world
+		res.AliveCells = calculateAliveCells(w.Param, world)
+	case 'k':
+		select {
+		case w.exitChan <- true:
+		case <-finished:
+		}
+		w.mutex.Lock()
+		world, err := w.backend.World()
+		res.Turn = w.currentTurn
+		w.mutex.Unlock()
+		if err == nil && w.checkpoints != nil {
+			w.saveCheckpoint(world, res.Turn)
+		}
+		w.kill()
+		if err != nil {
+			return err
+		}
+		res.World = world
+		res.AliveCells = calculateAliveCells(w.Param, world)
+	case 'n', '[', ']':
+		return w.controlKey(req.Key, res)
+	}
+	return nil
+}
+
+// CalculateStrip advances one strip of the world by a turn on behalf of a broker.
+func (w *Worker) CalculateStrip(req stubs.StripRequest, res *stubs.StripResponse) error {
+	if err := CheckParams(req.Params); err != nil {
+		return err
+	}
+	world := &util.Grid{Width: req.Params.ImageWidth, Height: req.Params.ImageHeight, Bits: GridBits(req.Params), Rows: make([][]byte, req.Params.ImageHeight)}
+	for y, row := range req.Rows {
+		world.Rows[y] = row
+	}
+	res.Strip, res.Flipped = CalculateStrip(world, req.StartY, req.EndY, req.Params)
+	return nil
+}
+
+// Ping lets a broker check that this worker is still alive.
+func (w *Worker) Ping(req stubs.PingRequest, res *stubs.PingResponse) error {
+	return nil
+}
+
+// Shutdown asks the hosting process to exit, as if k had been pressed.
+func (w *Worker) Shutdown(req stubs.ShutdownRequest, res *stubs.ShutdownResponse) error {
+	w.kill()
+	return nil
+}
+
+func (w *Worker) kill() {
+	w.killOnce.Do(func() {
+		close(w.killed)
+	})
+}
@@ -0,0 +1,291 @@
+package engine
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/internal/fixture"
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// eventually polls done until it reports true, failing the test if it has not within a few
+// seconds, so tests wait on the worker's state rather than for a fixed time.
+func eventually(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// serve registers worker with an RPC server of its own on a loopback port and returns a
+// client connected to it.
+func serve(t *testing.T, worker *Worker) (*rpc.Client, net.Listener) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := rpc.NewServer()
+	if err := server.Register(worker); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(listener)
+	client, err := rpc.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, listener
+}
+
+// TestRemoteWorker tests that a worker reached over RPC runs the check/images fixtures.
+func TestRemoteWorker(t *testing.T) {
+	client, listener := serve(t, NewWorker())
+	defer listener.Close()
+	defer client.Close()
+	for _, size := range []int{16, 64, 512} {
+		world := fixture.World(t, size, 0)
+		for _, turns := range []int{0, 1, 100} {
+			p := stubs.Params{ImageWidth: size, ImageHeight: size, Turns: turns, Threads: 4}
+			var res stubs.GameOfLifeResponse
+			err := client.Call(stubs.GameOfLife, stubs.GameOfLifeRequest{World: world, Params: p}, &res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := fmt.Sprintf("%dx%dx%d", size, size, turns)
+			if res.Turns != turns {
+				t.Errorf("%s: %d turns completed, want %d", name, res.Turns, turns)
+			}
+			fixture.AssertSameWorld(t, res.World, fixture.World(t, size, turns), name)
+		}
+	}
+}
+
+// TestRemoteStrips tests that strips calculated over RPC, as the broker's strip mode asks
+// for them, join up into the fixture's next turn.
+func TestRemoteStrips(t *testing.T) {
+	client, listener := serve(t, NewWorker())
+	defer listener.Close()
+	defer client.Close()
+	p := stubs.Params{ImageWidth: 64, ImageHeight: 64, Threads: 2}
+	world := fixture.World(t, 64, 0)
+	const strips = 3
+	next := &util.Grid{Width: 64, Height: 64}
+	for i := 0; i < strips; i++ {
+		startY, endY := StripBounds(i, strips, p)
+		req := stubs.StripRequest{Params: p, StartY: startY, EndY: endY, Rows: make(map[int][]byte)}
+		for y := startY; y < endY; y++ {
+			req.Rows[y] = world.Rows[y]
+		}
+		for _, y := range HaloRows(startY, endY, p) {
+			req.Rows[y] = world.Rows[y]
+		}
+		var res stubs.StripResponse
+		if err := client.Call(stubs.CalculateStrip, req, &res); err != nil {
+			t.Fatal(err)
+		}
+		next.Rows = append(next.Rows, res.Strip...)
+	}
+	fixture.AssertSameWorld(t, next, fixture.World(t, 64, 1), "64x64x1")
+}
+
+// readFlips fetches the turns of session after since with GetFlips until the stream ends,
+// waiting delay before each call. It checks that the session never runs more than
+// flipBuffer turns ahead of what has been fetched.
+func readFlips(w *Worker, session string, since int, delay time.Duration) ([]stubs.TurnFlips, error) {
+	var turns []stubs.TurnFlips
+	for {
+		time.Sleep(delay)
+		if ahead := w.turn() - since; ahead > flipBuffer {
+			return nil, fmt.Errorf("session ran %d turns ahead of turn %d", ahead, since)
+		}
+		var res stubs.GetFlipsResponse
+		if err := w.GetFlips(stubs.GetFlipsRequest{Session: session, SinceTurn: since}, &res); err != nil {
+			return nil, err
+		}
+		if len(res.Turns) > flipBuffer {
+			return nil, fmt.Errorf("%d turns fetched at once, more than %d", len(res.Turns), flipBuffer)
+		}
+		for _, flips := range res.Turns {
+			if flips.Turn != since+1 {
+				return nil, fmt.Errorf("turn %d fetched after turn %d", flips.Turn, since)
+			}
+			since = flips.Turn
+			turns = append(turns, flips)
+		}
+		if res.Done {
+			return turns, nil
+		}
+	}
+}
+
+// applyFlips returns world with the cells flipped by each of turns flipped.
+func applyFlips(world *util.Grid, turns []stubs.TurnFlips) *util.Grid {
+	world = world.Clone()
+	for _, flips := range turns {
+		for _, cell := range flips.Cells {
+			world.Set(cell.X, cell.Y, !world.Alive(cell.X, cell.Y))
+		}
+	}
+	return world
+}
+
+// detach presses q until the controller waiting on session is released, and returns what
+// its Wait call was given.
+func detach(t *testing.T, w *Worker, session string) stubs.GameOfLifeResponse {
+	t.Helper()
+	waited := make(chan stubs.GameOfLifeResponse)
+	errs := make(chan error)
+	go func() {
+		var res stubs.GameOfLifeResponse
+		if err := w.Wait(stubs.WaitRequest{Session: session}, &res); err != nil {
+			errs <- err
+			return
+		}
+		waited <- res
+	}()
+	// q only releases a Wait call already made, so it is pressed until one is.
+	for {
+		if err := w.KeyPress(stubs.KeyPressRequest{Key: 'q'}, &stubs.KeyPressResponse{}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case res := <-waited:
+			if !res.Detached {
+				t.Fatalf("Wait returned at turn %d without detaching", res.Turns)
+			}
+			return res
+		case err := <-errs:
+			t.Fatal(err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// TestAttachDetach tests that a session carries on while no controller is attached, and
+// that a controller which attaches to it again, by parameters or by name, is given the
+// world and then every turn after it.
+func TestAttachDetach(t *testing.T) {
+	w := NewWorker()
+	p := stubs.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 2}
+	var started stubs.StartResponse
+	if err := w.Start(stubs.GameOfLifeRequest{World: fixture.World(t, 64, 0), Params: p}, &started); err != nil {
+		t.Fatal(err)
+	}
+	session := started.Session
+	if err := w.Control(stubs.ControlRequest{Session: session, TurnsPerSecond: 100}, &stubs.ControlResponse{}); err != nil {
+		t.Fatal(err)
+	}
+
+	detached := detach(t, w, session)
+	eventually(t, "the detached session takes a turn", func() bool { return w.turn() > detached.Turns })
+	// The thread count may differ when attaching by parameters.
+	byParams := p
+	byParams.Threads = 4
+	var attached stubs.AttachResponse
+	if err := w.Attach(stubs.AttachRequest{Params: byParams}, &attached); err != nil {
+		t.Fatal(err)
+	}
+	if !attached.Found || attached.Session != session || attached.Paused {
+		t.Fatalf("attached to %+v, want running session %s", attached, session)
+	}
+	if attached.Turn <= detached.Turns {
+		t.Errorf("session stayed at turn %d while detached", attached.Turn)
+	}
+
+	// Pause it while detached, then attach by name.
+	detach(t, w, session)
+	var key stubs.KeyPressResponse
+	if err := w.KeyPress(stubs.KeyPressRequest{Key: 'p'}, &key); err != nil {
+		t.Fatal(err)
+	}
+	if !key.Paused {
+		t.Fatal("p did not pause the session")
+	}
+	var other stubs.AttachResponse
+	if err := w.Attach(stubs.AttachRequest{Session: "other", Params: p}, &other); err != nil || other.Found {
+		t.Fatalf("attached to session %q by the wrong name: %v", other.Session, err)
+	}
+	attached = stubs.AttachResponse{}
+	if err := w.Attach(stubs.AttachRequest{Session: session}, &attached); err != nil {
+		t.Fatal(err)
+	}
+	if !attached.Found || !attached.Paused || attached.Turn != key.Turn {
+		t.Fatalf("attached to %+v, want session %s paused at turn %d", attached, session, key.Turn)
+	}
+	// Once p has paused the session, no turn can be started, so its turn must not have moved.
+	if turn := w.turn(); turn != key.Turn {
+		t.Fatalf("paused session moved from turn %d to %d", key.Turn, turn)
+	}
+
+	type fetched struct {
+		turns []stubs.TurnFlips
+		err   error
+	}
+	flips := make(chan fetched)
+	go func() {
+		turns, err := readFlips(w, session, attached.Turn, 0)
+		flips <- fetched{turns, err}
+	}()
+	if err := w.Control(stubs.ControlRequest{Session: session, TurnsPerSecond: -1}, &stubs.ControlResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.KeyPress(stubs.KeyPressRequest{Key: 'p'}, &key); err != nil || key.Paused {
+		t.Fatalf("p did not resume the session: %v", err)
+	}
+	var res stubs.GameOfLifeResponse
+	if err := w.Wait(stubs.WaitRequest{Session: session}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Detached || res.Turns != 100 {
+		t.Fatalf("session finished at turn %d, detached %v, want turn 100", res.Turns, res.Detached)
+	}
+	expected := fixture.World(t, 64, 100)
+	fixture.AssertSameWorld(t, res.World, expected, "re-attached 64x64x100")
+	got := <-flips
+	if got.err != nil {
+		t.Fatal(got.err)
+	}
+	if len(got.turns) != 100-attached.Turn {
+		t.Errorf("%d turns fetched after turn %d, want %d", len(got.turns), attached.Turn, 100-attached.Turn)
+	}
+	fixture.AssertSameWorld(t, applyFlips(attached.World, got.turns), expected, "attached world with fetched flips")
+}
+
+// TestGetFlipsBackPressure tests that a session waits for a controller that is slow to
+// fetch its turns, holding no more than flipBuffer of them, and loses none.
+func TestGetFlipsBackPressure(t *testing.T) {
+	w := NewWorker()
+	p := stubs.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 2}
+	world := fixture.World(t, 64, 0)
+	var started stubs.StartResponse
+	if err := w.Start(stubs.GameOfLifeRequest{World: world, Params: p}, &started); err != nil {
+		t.Fatal(err)
+	}
+	// With nothing fetched, the session stops once the buffer is full.
+	eventually(t, "the flip buffer is full", func() bool { return w.turn() >= flipBuffer })
+	if turn := w.turn(); turn != flipBuffer {
+		t.Fatalf("session at turn %d with no turns fetched, want %d", turn, flipBuffer)
+	}
+
+	turns, err := readFlips(w, started.Session, 0, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res stubs.GameOfLifeResponse
+	if err := w.Wait(stubs.WaitRequest{Session: started.Session}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(turns) != 100 || res.Turns != 100 {
+		t.Fatalf("%d turns fetched and %d run, want 100", len(turns), res.Turns)
+	}
+	expected := fixture.World(t, 64, 100)
+	fixture.AssertSameWorld(t, res.World, expected, "64x64x100")
+	fixture.AssertSameWorld(t, applyFlips(world, turns), expected, "fetched flips")
+}
@@ -0,0 +1,72 @@
+package gol
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/engine"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// cellsFormat is the LifeWiki plaintext format: lines starting with ! are comments, and
+// each other line is a row of cells, O for alive and . for dead. Rows may be cut short, so
+// the pattern is as wide as its longest row. It has no dying states, so they are saved as
+// dead cells.
+var cellsFormat = &format{name: "cells", extension: "cells", read: readCells, write: writeCells}
+
+func readCells(r io.Reader, opts readOptions) (*util.Grid, string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+	states := opts.states
+	var rows []string
+	width := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		rows = append(rows, line)
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+	// A final newline does not start another row.
+	if len(rows) > 0 && rows[len(rows)-1] == "" {
+		rows = rows[:len(rows)-1]
+	}
+	world := util.NewStateGrid(width, len(rows), states)
+	for y, row := range rows {
+		for x, c := range row {
+			switch c {
+			case 'O', '*':
+				world.Set(x, y, true)
+			case '.':
+			default:
+				return nil, "", fmt.Errorf("invalid character %q in plaintext pattern", c)
+			}
+		}
+	}
+	return world, "", nil
+}
+
+func writeCells(w io.Writer, world *util.Grid, rule engine.Rule, opts writeOptions) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "!Rule: %v\n", rule)
+	row := make([]byte, world.Width)
+	for y := 0; y < world.Height; y++ {
+		for x := range row {
+			row[x] = '.'
+			if world.Alive(x, y) {
+				row[x] = 'O'
+			}
+		}
+		out.Write(row)
+		out.WriteString("\n")
+	}
+	return out.Flush()
+}
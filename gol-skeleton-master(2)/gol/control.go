@@ -0,0 +1,95 @@
+package gol
+
+import (
+	"log"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// maxTyped is the largest turn typed for u that another digit is added to, so the turn
+// cannot overflow.
+const maxTyped = 100000000
+
+// speed is the speed limit and the turn to run until, as the engine last reported them,
+// which every StateChange carries. The goroutines that report state changes share it.
+type speed struct {
+	mutex          sync.Mutex
+	turnsPerSecond float64
+	until          int
+}
+
+func (s *speed) set(turnsPerSecond float64, until int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.turnsPerSecond, s.until = turnsPerSecond, until
+}
+
+// reached forgets the turn to run until, once the engine has paused there.
+func (s *speed) reached() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.until = 0
+}
+
+// stateChange returns the StateChange event for the session entering state at turn.
+func (s *speed) stateChange(turn int, state State) StateChange {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return StateChange{CompletedTurns: turn, NewState: state, TurnsPerSecond: s.turnsPerSecond, Until: s.until}
+}
+
+// control asks the engine for the speed limit and the turn to run until given in p, if
+// either is, and reports the session's state with them.
+func control(golWorker Engine, p Params, session string, turn int, paused bool, s *speed, c distributorChannels) {
+	if p.TurnsPerSecond <= 0 && p.Until <= 0 {
+		return
+	}
+	var res stubs.ControlResponse
+	err := golWorker.Control(stubs.ControlRequest{Session: session, TurnsPerSecond: p.TurnsPerSecond, Until: p.Until}, &res)
+	if err != nil {
+		log.Printf("Control call failed: %v", err)
+		return
+	}
+	s.set(res.TurnsPerSecond, res.Until)
+	state := Executing
+	if paused {
+		state = Paused
+	}
+	c.events <- s.stateChange(turn, state)
+}
+
+// controlKey reports the effect of the keys that step the session and change its speed, or
+// a Notice if n was pressed while the session was running.
+func controlKey(key rune, res stubs.KeyPressResponse, s *speed, c distributorChannels) {
+	s.set(res.TurnsPerSecond, res.Until)
+	if key == 'n' && !res.Paused {
+		c.events <- Notice{CompletedTurns: res.Turn, Message: "Pause with p before stepping"}
+		return
+	}
+	state := Executing
+	if res.Paused {
+		state = Paused
+	}
+	c.events <- s.stateChange(res.Turn, state)
+}
+
+// untilKey asks the engine to run the session until the turn typed before u, or to forget
+// the turn to run until if none was, and reports the session's state with it.
+func untilKey(golWorker Engine, session string, until int, s *speed, c distributorChannels) {
+	if until == 0 {
+		until = -1
+	}
+	var res stubs.ControlResponse
+	err := golWorker.Control(stubs.ControlRequest{Session: session, Until: until}, &res)
+	if err != nil {
+		log.Printf("Control call failed: %v", err)
+		return
+	}
+	s.set(res.TurnsPerSecond, res.Until)
+	state := Executing
+	if res.Paused {
+		state = Paused
+	}
+	c.events <- s.stateChange(res.Turn, state)
+}
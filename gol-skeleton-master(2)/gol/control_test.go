@@ -0,0 +1,177 @@
+package gol
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// session reads the events of a run, keeping track of the last turn completed.
+type session struct {
+	t      *testing.T
+	events <-chan Event
+	turn   int
+	// final is the FinalTurnComplete event, once it has been read.
+	final *FinalTurnComplete
+}
+
+// read reads one event, or fails the test if none comes within a few seconds or a turn
+// past maxTurn is completed.
+func (s *session) read(maxTurn int) Event {
+	s.t.Helper()
+	select {
+	case event, ok := <-s.events:
+		if !ok {
+			s.t.Fatal("events closed early")
+		}
+		switch e := event.(type) {
+		case TurnComplete:
+			if e.CompletedTurns > maxTurn {
+				s.t.Fatalf("turn %d completed, want none past %d", e.CompletedTurns, maxTurn)
+			}
+			s.turn = e.CompletedTurns
+		case FinalTurnComplete:
+			s.final = &e
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		s.t.Fatalf("no event after turn %d", s.turn)
+	}
+	return nil
+}
+
+// stateChange reads events until a StateChange, completing no turn past maxTurn.
+func (s *session) stateChange(maxTurn int) StateChange {
+	s.t.Helper()
+	for {
+		if e, ok := s.read(maxTurn).(StateChange); ok {
+			return e
+		}
+	}
+}
+
+// paused reads events until the run pauses, completing no turn past maxTurn, and returns
+// the turn it paused at.
+func (s *session) paused(maxTurn int) int {
+	s.t.Helper()
+	for {
+		if e := s.stateChange(maxTurn); e.NewState == Paused {
+			return e.CompletedTurns
+		}
+	}
+}
+
+// quiet reads events for a while, failing if a turn past maxTurn is completed.
+func (s *session) quiet(maxTurn int) {
+	s.t.Helper()
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case event := <-s.events:
+			if e, ok := event.(TurnComplete); ok {
+				if e.CompletedTurns > maxTurn {
+					s.t.Fatalf("turn %d completed while paused at %d", e.CompletedTurns, maxTurn)
+				}
+				s.turn = e.CompletedTurns
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// TestUntilAndStep tests that a run pauses exactly at the turn Until gives, that n then
+// steps it by exactly one turn, and that a turn typed before u is run until in turn.
+func TestUntilAndStep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := Params{Input: "../images/64x64.pgm", ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 2,
+		Until: 40, Engine: LocalEngine, OutputDir: dir}
+	events := make(chan Event)
+	keyPresses := make(chan rune, 10)
+	go Run(p, events, keyPresses)
+	s := &session{t: t, events: events}
+
+	if turn := s.paused(40); turn != 40 || s.turn != 40 {
+		t.Fatalf("paused at turn %d after completing turn %d, want 40", turn, s.turn)
+	}
+	s.quiet(40)
+
+	keyPresses <- 'n'
+	if turn := s.paused(41); turn != 41 {
+		t.Fatalf("n stepped to turn %d, want 41", turn)
+	}
+	s.quiet(41)
+	if s.turn != 41 {
+		t.Fatalf("turn %d completed after n, want 41", s.turn)
+	}
+
+	for _, key := range "60u" {
+		keyPresses <- key
+	}
+	if e := s.stateChange(41); e.Until != 60 || e.NewState != Paused {
+		t.Fatalf("typing 60u gave %v, want paused until turn 60", e)
+	}
+	keyPresses <- 'p'
+	if turn := s.paused(60); turn != 60 {
+		t.Fatalf("paused at turn %d, want 60", turn)
+	}
+	if s.turn != 60 {
+		t.Fatalf("paused at turn 60 after completing turn %d", s.turn)
+	}
+
+	keyPresses <- 'p'
+	for s.final == nil {
+		s.read(100)
+	}
+	if s.final.CompletedTurns != 100 {
+		t.Errorf("run finished at turn %d, want 100", s.final.CompletedTurns)
+	}
+	expected, _, err := readPattern("../check/images/64x64x100.pgm", pgmFormat, readOptions{states: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.final.Alive) != expected.Count() {
+		t.Errorf("%d cells alive at turn 100, want %d", len(s.final.Alive), expected.Count())
+	}
+	for _, cell := range s.final.Alive {
+		if !expected.Alive(cell.X, cell.Y) {
+			t.Fatalf("cell %v alive at turn 100, want dead", cell)
+		}
+	}
+	for range events {
+	}
+}
+
+// TestControlKey tests that the keys that step and change the speed of a session report
+// their effect as events, and that n pressed while running gives a Notice instead.
+func TestControlKey(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		key  rune
+		res  stubs.KeyPressResponse
+		want Event
+	}{
+		{"step", 'n', stubs.KeyPressResponse{Turn: 5, Paused: true},
+			StateChange{CompletedTurns: 5, NewState: Paused}},
+		{"step while running", 'n', stubs.KeyPressResponse{Turn: 5},
+			Notice{CompletedTurns: 5, Message: "Pause with p before stepping"}},
+		{"slow down", '[', stubs.KeyPressResponse{Turn: 7, TurnsPerSecond: 64, Until: 20},
+			StateChange{CompletedTurns: 7, NewState: Executing, TurnsPerSecond: 64, Until: 20}},
+		{"no limit", ']', stubs.KeyPressResponse{Turn: 9, Paused: true},
+			StateChange{CompletedTurns: 9, NewState: Paused}},
+	} {
+		events := make(chan Event, 1)
+		controlKey(test.key, test.res, &speed{}, distributorChannels{events: events})
+		if got := <-events; got != test.want {
+			t.Errorf("%s: got %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}
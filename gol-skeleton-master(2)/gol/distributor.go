@@ -3,22 +3,27 @@ package gol
 import (
 	"fmt"
 	"log"
-	"net/rpc"
-	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"uk.ac.bris.cs/gameoflife/engine"
 	"uk.ac.bris.cs/gameoflife/stubs"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
 type distributorChannels struct {
-	events     chan<- Event
-	ioCommand  chan<- ioCommand
-	ioIdle     <-chan bool
-	ioFilename chan<- string
-	ioOutput   chan<- uint8
-	ioInput    <-chan uint8
-	keyPresses <-chan rune
+	events       chan<- Event
+	ioCommand    chan<- ioCommand
+	ioIdle       <-chan bool
+	ioFilename   chan<- string
+	ioOutput     chan<- *util.Grid
+	ioInput      <-chan *util.Grid
+	ioInputError <-chan error
+	ioWritten    <-chan error
+	keyPresses   <-chan rune
+	edits        <-chan Edit
 }
 
 type workerChannels struct {
@@ -27,150 +32,386 @@ type workerChannels struct {
 }
 
 // distributor divides the work between workers and interacts with other goroutines.
-func distributor(p Params, c distributorChannels) {
-	wd := p.ImageWidth
-	hd := p.ImageHeight
-	ChannelClosed := false
-	c.ioCommand <- ioInput
-	filename1 := fmt.Sprintf("%dx%d", hd, wd)
-	c.ioFilename <- filename1
-	//  Create a 2D slice to store the world.
-	world := makeNewWorld(hd, wd)
-	for i := range world {
-		world[i] = make([]byte, wd)
-		for j := range world[i] {
-			world[i][j] = <-c.ioInput
-			if world[i][j] == 255 {
-				c.events <- CellFlipped{
-					Cell:           util.Cell{X: j, Y: i},
-					CompletedTurns: 0,
-				}
-			}
-		}
-	}
-	turn := 0
-	golWorker, err := rpc.Dial("tcp", "54.163.128.97:8030")
+// If the engine already has a matching session, left running by a controller that pressed q,
+// the distributor reattaches to it instead of loading the image.
+func distributor(p Params, rule engine.Rule, cells *history, c distributorChannels) {
+	golWorker, err := newEngine(p)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
 	defer golWorker.Close()
-	go timer(golWorker, c.events, &ChannelClosed)
-	go keypress(golWorker, p, c, &ChannelClosed)
+	topology, err := engine.ParseTopology(p.Topology)
+	if err != nil {
+		log.Fatal(err)
+	}
+	params := stubs.Params{
+		ImageWidth:  p.ImageWidth,
+		ImageHeight: p.ImageHeight,
+		Turns:       p.Turns,
+		Threads:     p.Threads,
+		Rule:        rule.String(),
+		Topology:    topology.String(),
+		Algorithm:   p.Algorithm,
+	}
+	var attach stubs.AttachResponse
+	err = golWorker.Attach(stubs.AttachRequest{Session: p.Session, Params: params}, &attach)
+	if err != nil {
+		log.Fatalf("Attach call failed: %v", err)
+	}
+	if p.Session != "" && !attach.Found {
+		log.Fatalf("Session %s is not running on the server", p.Session)
+	}
+	var world *util.Grid
+	var session string
+	turn := 0
+	limits := &speed{}
+	if attach.Found {
+		c.events <- Notice{CompletedTurns: attach.Turn, Message: fmt.Sprintf("Reattached to session %s", attach.Session)}
+		session = attach.Session
+		world = attach.World
+		turn = attach.Turn
+		reportChanges(util.NewStateGrid(p.ImageWidth, p.ImageHeight, rule.States), world, rule, turn, c)
+		limits.set(attach.TurnsPerSecond, attach.Until)
+		if attach.Paused {
+			c.events <- limits.stateChange(turn, Paused)
+		}
+	} else {
+		world = readWorld(p, rule, c)
+		var start stubs.StartResponse
+		err = golWorker.Start(stubs.GameOfLifeRequest{World: world, Params: params}, &start)
+		if err != nil {
+			log.Fatalf("Start call failed: %v", err)
+		}
+		session = start.Session
+		if start.World != nil {
+			c.events <- Notice{CompletedTurns: start.Turn, Message: "Resumed from checkpoint"}
+			turn = start.Turn
+			reportChanges(world, start.World, rule, turn, c)
+			world = start.World
+		}
+	}
+	control(golWorker, p, session, turn, attach.Paused, limits, c)
+	// done tells the timer and keypress goroutines to stop before the events channel is closed.
+	done := make(chan bool)
+	var helpers sync.WaitGroup
+	helpers.Add(3)
+	go timer(golWorker, c.events, done, &helpers)
+	go keypress(golWorker, p, session, limits, c, done, &helpers)
+	go editor(golWorker, session, c, done, &helpers)
+	relayed := make(chan bool)
+	go relayFlips(golWorker, p, session, turn, world, rule, limits, cells, c, relayed)
 	var res stubs.GameOfLifeResponse
-	req := stubs.GameOfLifeRequest{
-		World: world,
-		Params: stubs.Params{
-			ImageWidth:  p.ImageWidth,
-			ImageHeight: p.ImageHeight,
-			Turns:       p.Turns,
-			Threads:     p.Threads,
-		},
-	}
-	err = golWorker.Call(stubs.GameOfLife, req, &res)
+	err = golWorker.Wait(stubs.WaitRequest{Session: session}, &res)
 	if err != nil {
-		panic(err)
+		log.Fatalf("Wait call failed: %v", err)
+	}
+	// Every turn must be reported before FinalTurnComplete.
+	<-relayed
+	if p.Record > 0 {
+		saveRecording(c, p, res.Turns)
 	}
+	if res.Detached {
+		c.events <- Notice{CompletedTurns: res.Turns, Message: fmt.Sprintf("Detached from session %s", res.Session)}
+	}
+	close(done)
+	helpers.Wait()
 	world = res.World
 	turn = res.Turns
 	outPutFile(world, c, p, turn)
 	// Report the final state using FinalTurnCompleteEvent.
-	c.events <- FinalTurnComplete{CompletedTurns: p.Turns, Alive: res.AliveCells}
+	c.events <- FinalTurnComplete{CompletedTurns: turn, Alive: res.AliveCells}
 	c.ioCommand <- ioCheckIdle
 	<-c.ioIdle
-	c.events <- StateChange{turn, Quitting}
-	ChannelClosed = true
+	c.events <- limits.stateChange(turn, Quitting)
 	close(c.events)
 }
 
-func makeNewWorld(height, width int) [][]uint8 {
-	newWorld := make([][]uint8, height)
-	for i := range newWorld {
-		newWorld[i] = make([]uint8, width)
+// readWorld loads the input image, reporting every cell that is not dead.
+func readWorld(p Params, rule engine.Rule, c distributorChannels) *util.Grid {
+	wd := p.ImageWidth
+	hd := p.ImageHeight
+	c.ioCommand <- ioInput
+	c.ioFilename <- inputPath(p)
+	var world *util.Grid
+	select {
+	case world = <-c.ioInput:
+	case err := <-c.ioInputError:
+		log.Fatal(err)
+	}
+	reportChanges(util.NewStateGrid(wd, hd, rule.States), world, rule, 0, c)
+	return world
+}
+
+// reportCell tells the GUI that cell is now in state: with CellFlipped for two-state rules,
+// where state is implied, and with CellStateChanged for Generations rules.
+func reportCell(cell util.Cell, state uint8, rule engine.Rule, turn int, c distributorChannels) {
+	if rule.States > 2 {
+		c.events <- CellStateChanged{
+			Cell:           cell,
+			State:          state,
+			States:         rule.States,
+			CompletedTurns: turn,
+		}
+	} else {
+		c.events <- CellFlipped{
+			Cell:           cell,
+			CompletedTurns: turn,
+		}
+	}
+}
+
+// reportChanges reports every cell whose state differs between from and to, within the
+// area of from. An unbounded world may have grown beyond it, so cells are compared at
+// their world coordinates.
+func reportChanges(from, to *util.Grid, rule engine.Rule, turn int, c distributorChannels) {
+	for y := 0; y < from.Height; y++ {
+		for x := 0; x < from.Width; x++ {
+			if state := to.StateAt(x, y); state != from.State(x, y) {
+				reportCell(util.Cell{X: x, Y: y}, state, rule, turn, c)
+			}
+		}
 	}
-	return newWorld
 }
 
-func timer(golWorker *rpc.Client, eventChan chan<- Event, ChannelClosed *bool) {
+// relayFlips fetches the turns of the session from the engine as they complete, reporting
+// each changed cell and then TurnComplete. Edits are reported in the same way, as a repeat
+// of the turn they were made after. The engine holds the session back while it is far
+// behind, so a slow consumer of events slows the simulation down rather than missing
+// turns. relayed is closed once the session ends or q detaches.
+//
+// The engine only lists the cells each turn changed. Under a Generations rule their new
+// states are found by following them on a copy of world, the world at the given turn.
+// Cells of an unbounded world that lie outside the image are not reported. When recording,
+// the copy is also sent to the io goroutine every p.Record turns, with a Notice once the
+// recording is full. When images are coloured by age or activity, every change is also added
+// to the cells' history.
+//
+// When the session pauses itself on reaching the turn it was to run until, StateChange
+// follows that turn's TurnComplete.
+func relayFlips(golWorker Engine, p Params, session string, turn int, world *util.Grid, rule engine.Rule, limits *speed, cells *history, c distributorChannels, relayed chan<- bool) {
+	defer close(relayed)
+	var shown *util.Grid
+	if rule.States > 2 || p.Record > 0 || cells != nil {
+		shown = view(world, p, rule)
+	}
+	cells.load(world, rule, turn)
+	frames := 0
+	if p.Record > 0 {
+		frames = recordFrame(shown, p, frames, turn, c)
+	}
+	edits := 0
 	for {
-		time.Sleep(time.Second * 2)
+		var res stubs.GetFlipsResponse
+		err := golWorker.GetFlips(stubs.GetFlipsRequest{Session: session, SinceTurn: turn, SinceEdits: edits}, &res)
+		if err != nil {
+			log.Printf("GetFlips call failed: %v", err)
+			return
+		}
+		for _, flips := range res.Turns {
+			for _, cell := range flips.Cells {
+				if cell.X < 0 || cell.X >= p.ImageWidth || cell.Y < 0 || cell.Y >= p.ImageHeight {
+					continue
+				}
+				var state uint8
+				if shown != nil {
+					switch {
+					case !flips.Edit:
+						state = rule.Advance(shown.State(cell.X, cell.Y))
+					case flips.Alive:
+						state = 1
+					}
+					shown.SetState(cell.X, cell.Y, state)
+				}
+				cells.set(cell, state, rule, flips.Turn)
+				reportCell(cell, state, rule, flips.Turn, c)
+			}
+			cells.complete(flips.Turn)
+			c.events <- TurnComplete{CompletedTurns: flips.Turn}
+			if flips.Paused {
+				limits.reached()
+				c.events <- limits.stateChange(flips.Turn, Paused)
+			}
+			if p.Record > 0 && flips.Turn/p.Record > turn/p.Record {
+				frames = recordFrame(shown, p, frames, flips.Turn, c)
+			}
+			turn, edits = flips.Turn, flips.Edits
+		}
+		if res.Done {
+			return
+		}
+	}
+}
+
+// view returns the part of world that the image covers, as a grid of its own.
+func view(world *util.Grid, p Params, rule engine.Rule) *util.Grid {
+	shown := util.NewStateGrid(p.ImageWidth, p.ImageHeight, rule.States)
+	for y := 0; y < p.ImageHeight; y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			shown.SetState(x, y, world.StateAt(x, y))
+		}
+	}
+	return shown
+}
+
+// recordFrame adds a copy of world at turn to the recording, which has been sent the given
+// number of frames, and returns the number sent after. The io goroutine leaves out frames
+// past the recording's limit, so the first of them is reported with a Notice.
+func recordFrame(world *util.Grid, p Params, frames, turn int, c distributorChannels) int {
+	if limit := frameLimit(p); frames == limit {
+		c.events <- Notice{CompletedTurns: turn, Message: fmt.Sprintf("Recording full at %d frames", limit)}
+	}
+	c.ioCommand <- ioFrame
+	c.ioOutput <- world.Clone()
+	return frames + 1
+}
 
-		if !*ChannelClosed {
+// saveRecording writes the recording as an animated GIF named like images of the turn it ends on.
+func saveRecording(c distributorChannels, p Params, turn int) {
+	c.ioCommand <- ioRecording
+	name := outputName(p, p.ImageWidth, p.ImageHeight, turn)
+	c.ioFilename <- name
+	err := <-c.ioWritten
+	if _, partial := err.(framesDropped); err != nil && !partial {
+		log.Printf("Recording not saved: %v", err)
+		return
+	}
+	if err != nil {
+		log.Printf("Recording %s %v", name, err)
+	}
+	c.events <- ImageOutputComplete{
+		CompletedTurns: turn,
+		Filename:       name,
+	}
+}
+
+func timer(golWorker Engine, eventChan chan<- Event, done <-chan bool, helpers *sync.WaitGroup) {
+	defer helpers.Done()
+	ticker := time.NewTicker(time.Second * 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
 			var res stubs.GetAliveCellsResponse
-			err := golWorker.Call(stubs.GetAliveCells, stubs.GetAliveCellsRequest{}, &res)
+			err := golWorker.GetAliveCells(stubs.GetAliveCellsRequest{}, &res)
 			if err != nil {
 				log.Printf("Error: %v", err)
+				continue
+			}
+			select {
+			case eventChan <- AliveCellsCount{CellsCount: res.AliveCellsCount, CompletedTurns: res.Turn}:
+			case <-done:
+				return
 			}
-			eventChan <- AliveCellsCount{CellsCount: res.AliveCellsCount, CompletedTurns: res.Turn}
 		}
 	}
 }
 
-func outPutFile(world [][]uint8, c distributorChannels, p Params, turn int) {
-	HD := strconv.Itoa(p.ImageHeight)
-	WD := strconv.Itoa(p.ImageWidth)
-	TR := strconv.Itoa(turn)
+// outPutFile saves world as a PGM image. The world of an unbounded session is the bounding
+// box of its live cells, so the image takes its size from world rather than from p.
+func outPutFile(world *util.Grid, c distributorChannels, p Params, turn int) {
+	if world == nil {
+		return
+	}
 	c.ioCommand <- ioOutput
-	FilenameOut := WD + "x" + HD + "x" + TR
+	FilenameOut := outputName(p, world.Width, world.Height, turn)
 	c.ioFilename <- FilenameOut
-	if len(world) == 0 {
+	c.ioOutput <- world
+	// Wait for the file to be written, so ImageOutputComplete means what it says.
+	if err := <-c.ioWritten; err != nil {
+		log.Printf("Image not saved: %v", err)
 		return
 	}
-	hd := p.ImageHeight
-	wd := p.ImageWidth
-	for x := 0; x < hd; x++ {
-		for y := 0; y < wd; y++ {
-			c.ioOutput <- world[x][y]
-		}
-	}
 	c.events <- ImageOutputComplete{
 		CompletedTurns: turn,
 		Filename:       FilenameOut,
 	}
 }
 
-func keypress(golWorker *rpc.Client, p Params, c distributorChannels, ChannelClosed *bool) {
+// outputName is the name an image of the given size at turn is saved under. In
+// p.OutputName, {w}, {h} and {turn} stand for the size and the turn, and {input} for the
+// name of the input file without its extension.
+func outputName(p Params, width, height, turn int) string {
+	name := p.OutputName
+	if name == "" {
+		name = "{w}x{h}x{turn}"
+	}
+	input := filepath.Base(inputPath(p))
+	input = strings.TrimSuffix(input, filepath.Ext(input))
+	return strings.NewReplacer(
+		"{w}", strconv.Itoa(width),
+		"{h}", strconv.Itoa(height),
+		"{turn}", strconv.Itoa(turn),
+		"{input}", input,
+	).Replace(name)
+}
+
+// editor sends the user's edits to the engine. The cells they change come back through
+// relayFlips, in order with the turns.
+func editor(golWorker Engine, session string, c distributorChannels, done <-chan bool, helpers *sync.WaitGroup) {
+	defer helpers.Done()
+	for {
+		var edit Edit
+		select {
+		case edit = <-c.edits:
+		case <-done:
+			return
+		}
+		var res stubs.EditResponse
+		err := golWorker.Edit(stubs.EditRequest{Session: session, Cells: edit.Cells, Alive: edit.Alive}, &res)
+		if err != nil {
+			log.Printf("Edit call failed: %v", err)
+		}
+	}
+}
+
+// keypress forwards key presses to the engine. q detaches from the session and k ends it;
+// either makes the engine's Wait call return, after which the distributor writes the
+// final image and shuts down. n steps a paused session by a turn, and [ and ] slow it down
+// and speed it up. Digits typed before u give a turn to run until, and u alone forgets it.
+func keypress(golWorker Engine, p Params, session string, limits *speed, c distributorChannels, done <-chan bool, helpers *sync.WaitGroup) {
+	defer helpers.Done()
+	// typed is the number typed so far for u.
+	typed := 0
 	for {
-		key := <-c.keyPresses
+		var key rune
+		select {
+		case key = <-c.keyPresses:
+		case <-done:
+			return
+		}
+		switch {
+		case key >= '0' && key <= '9':
+			if typed < maxTyped {
+				typed = typed*10 + int(key-'0')
+			}
+			continue
+		case key == 'u':
+			untilKey(golWorker, session, typed, limits, c)
+			typed = 0
+			continue
+		}
+		typed = 0
 		var res stubs.KeyPressResponse
-		err := golWorker.Call(stubs.KeyPress, stubs.KeyPressRequest{Key: key}, &res)
+		err := golWorker.KeyPress(stubs.KeyPressRequest{Key: key}, &res)
 		if err != nil {
-			panic(err)
+			log.Printf("KeyPress call failed: %v", err)
+			continue
 		}
 		switch key {
 		case 's':
-			fallthrough
-		case 'k':
-			outPutFile(res.World, c, p, res.Turn)
-			if key == 'k' {
-				c.events <- FinalTurnComplete{CompletedTurns: p.Turns, Alive: res.AliveCells}
-				c.ioCommand <- ioCheckIdle
-				<-c.ioIdle
-				c.events <- StateChange{res.Turn, Quitting}
-				close(c.events)
-				os.Exit(0)
-			}
-		case 'q':
 			outPutFile(res.World, c, p, res.Turn)
-			c.events <- FinalTurnComplete{CompletedTurns: p.Turns, Alive: res.AliveCells}
-			c.ioCommand <- ioCheckIdle
-			<-c.ioIdle
-			c.events <- StateChange{res.Turn, Quitting}
-			close(c.events)
+		case 'q', 'k':
+			return
 		case 'p':
-			*ChannelClosed = true
-			c.events <- StateChange{res.Turn, Paused}
-			for {
-				Key := <-c.keyPresses
-				if Key == 'p' {
-					err := golWorker.Call(stubs.KeyPress, stubs.KeyPressRequest{Key: Key}, &res)
-					if err != nil {
-						panic(err)
-					}
-					*ChannelClosed = false
-					c.events <- StateChange{res.Turn, Executing}
-					break
-				}
+			if res.Paused {
+				c.events <- limits.stateChange(res.Turn, Paused)
+			} else {
+				c.events <- limits.stateChange(res.Turn, Executing)
 			}
+		case 'n', '[', ']':
+			controlKey(key, res, limits, c)
 		}
 	}
 }
@@ -0,0 +1,162 @@
+package gol
+
+import (
+	"fmt"
+	"log"
+	"net/rpc"
+	"os"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/engine"
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// Engine evolves the world on behalf of the distributor.
+// Its methods mirror the Worker RPCs named in stubs.
+type Engine interface {
+	Start(req stubs.GameOfLifeRequest, res *stubs.StartResponse) error
+	GetFlips(req stubs.GetFlipsRequest, res *stubs.GetFlipsResponse) error
+	GetAliveCells(req stubs.GetAliveCellsRequest, res *stubs.GetAliveCellsResponse) error
+	KeyPress(req stubs.KeyPressRequest, res *stubs.KeyPressResponse) error
+	Attach(req stubs.AttachRequest, res *stubs.AttachResponse) error
+	Wait(req stubs.WaitRequest, res *stubs.GameOfLifeResponse) error
+	Edit(req stubs.EditRequest, res *stubs.EditResponse) error
+	Control(req stubs.ControlRequest, res *stubs.ControlResponse) error
+	Close() error
+}
+
+const (
+	// serverEnv names the environment variable consulted when Params.Server is empty.
+	serverEnv     = "GOL_SERVER"
+	defaultServer = "127.0.0.1:8030"
+	dialAttempts  = 5
+	dialBackoff   = 200 * time.Millisecond
+)
+
+// Engine names accepted in Params.Engine.
+const (
+	LocalEngine  = "local"
+	RemoteEngine = "remote"
+)
+
+// localEngine runs the Worker in-process, with no network involved.
+type localEngine struct {
+	*engine.Worker
+}
+
+// KeyPress ends the session when q detaches from it, as with no server to keep it
+// the session could never be reattached to.
+func (e localEngine) KeyPress(req stubs.KeyPressRequest, res *stubs.KeyPressResponse) error {
+	err := e.Worker.KeyPress(req, res)
+	if req.Key == 'q' {
+		e.Stop()
+	}
+	return err
+}
+
+func (e localEngine) Close() error {
+	e.Stop()
+	return nil
+}
+
+// remoteEngine forwards every call to a GoL server over net/rpc.
+type remoteEngine struct {
+	client *rpc.Client
+}
+
+func (e remoteEngine) Start(req stubs.GameOfLifeRequest, res *stubs.StartResponse) error {
+	return e.client.Call(stubs.Start, req, res)
+}
+
+func (e remoteEngine) GetFlips(req stubs.GetFlipsRequest, res *stubs.GetFlipsResponse) error {
+	return e.client.Call(stubs.GetFlips, req, res)
+}
+
+func (e remoteEngine) GetAliveCells(req stubs.GetAliveCellsRequest, res *stubs.GetAliveCellsResponse) error {
+	return e.client.Call(stubs.GetAliveCells, req, res)
+}
+
+func (e remoteEngine) KeyPress(req stubs.KeyPressRequest, res *stubs.KeyPressResponse) error {
+	return e.client.Call(stubs.KeyPress, req, res)
+}
+
+func (e remoteEngine) Attach(req stubs.AttachRequest, res *stubs.AttachResponse) error {
+	return e.client.Call(stubs.Attach, req, res)
+}
+
+func (e remoteEngine) Wait(req stubs.WaitRequest, res *stubs.GameOfLifeResponse) error {
+	return e.client.Call(stubs.Wait, req, res)
+}
+
+func (e remoteEngine) Edit(req stubs.EditRequest, res *stubs.EditResponse) error {
+	return e.client.Call(stubs.Edit, req, res)
+}
+
+func (e remoteEngine) Control(req stubs.ControlRequest, res *stubs.ControlResponse) error {
+	return e.client.Call(stubs.Control, req, res)
+}
+
+func (e remoteEngine) Close() error {
+	return e.client.Close()
+}
+
+// engineName resolves which engine p asks for. If Params.Engine is empty the remote engine
+// is used only when a server address has been given, so plain 'go test' stays hermetic.
+func engineName(p Params) string {
+	if p.Engine != "" {
+		return p.Engine
+	}
+	if p.Server != "" || os.Getenv(serverEnv) != "" {
+		return RemoteEngine
+	}
+	return LocalEngine
+}
+
+// newEngine creates the engine selected by p.
+func newEngine(p Params) (Engine, error) {
+	switch name := engineName(p); name {
+	case LocalEngine:
+		return localEngine{engine.NewWorker()}, nil
+	case RemoteEngine:
+		client, err := dialServer(serverAddress(p))
+		if err != nil {
+			return nil, err
+		}
+		return remoteEngine{client}, nil
+	default:
+		return nil, fmt.Errorf("unknown engine %q (want %q or %q)", name, LocalEngine, RemoteEngine)
+	}
+}
+
+// serverAddress returns the address of the GoL server to dial for p.
+func serverAddress(p Params) string {
+	if p.Server != "" {
+		return p.Server
+	}
+	if env := os.Getenv(serverEnv); env != "" {
+		return env
+	}
+	return defaultServer
+}
+
+// dialServer connects to the GoL server, retrying with exponential backoff so that
+// a worker which is still starting up does not bring the controller down.
+func dialServer(address string) (*rpc.Client, error) {
+	delay := dialBackoff
+	var err error
+	for attempt := 1; attempt <= dialAttempts; attempt++ {
+		var client *rpc.Client
+		client, err = rpc.Dial("tcp", address)
+		if err == nil {
+			return client, nil
+		}
+		if attempt < dialAttempts {
+			log.Printf("Could not reach GoL server at %s (attempt %d/%d): %v; retrying in %v",
+				address, attempt, dialAttempts, err, delay)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return nil, fmt.Errorf("could not connect to GoL server at %s after %d attempts: %v "+
+		"(start one with 'go run ./worker' or set -server / %s)", address, dialAttempts, err, serverEnv)
+}
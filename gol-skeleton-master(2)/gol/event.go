@@ -39,9 +39,14 @@ const (
 
 // StateChange is an Event notifying the user about the change of state of execution.
 // This Event should be sent every time the execution is paused, resumed or quit.
+// It is also sent when a paused run is stepped by a turn, or its speed limit changes.
 type StateChange struct { // implements Event
 	CompletedTurns int
 	NewState       State
+	// TurnsPerSecond is the most turns a second the run may go at, or 0 if there is no
+	// limit, and Until is the turn it will pause at, or 0 if none.
+	TurnsPerSecond float64
+	Until          int
 }
 
 // CellFlipped is an Event notifying the GUI about a change of state of a single cell.
@@ -52,9 +57,20 @@ type CellFlipped struct { // implements Event
 	Cell           util.Cell
 }
 
+// CellStateChanged is an Event notifying the GUI about a change of state of a single cell
+// under a Generations rule, where cells have more than two states. It is sent instead of
+// CellFlipped, including for every cell that is not dead when the image is loaded in.
+// State 0 is dead, 1 is alive and 2 to States-1 are dying; util.StateGrey gives its colour.
+type CellStateChanged struct { // implements Event
+	CompletedTurns int
+	Cell           util.Cell
+	State          uint8
+	States         int
+}
+
 // TurnComplete is an Event notifying the GUI about turn completion.
 // SDL will render a frame when this event is sent.
-// All CellFlipped events must be sent *before* TurnComplete.
+// All CellFlipped and CellStateChanged events must be sent *before* TurnComplete.
 type TurnComplete struct { // implements Event
 	CompletedTurns int
 }
@@ -67,6 +83,13 @@ type FinalTurnComplete struct {
 	Alive          []util.Cell
 }
 
+// Notice is an Event carrying a message about the run for the GUI to show, such as the session
+// it attached to or a key that could not take effect.
+type Notice struct { // implements Event
+	CompletedTurns int
+	Message        string
+}
+
 // String methods allow the different types of Events and States to be printed.
 
 func (state State) String() string {
@@ -83,7 +106,14 @@ func (state State) String() string {
 }
 
 func (event StateChange) String() string {
-	return fmt.Sprintf("%v", event.NewState)
+	s := fmt.Sprintf("%v", event.NewState)
+	if event.TurnsPerSecond > 0 {
+		s += fmt.Sprintf(", at most %g turns/s", event.TurnsPerSecond)
+	}
+	if event.Until > 0 {
+		s += fmt.Sprintf(", until turn %d", event.Until)
+	}
+	return s
 }
 
 func (event StateChange) GetCompletedTurns() int {
@@ -114,6 +144,14 @@ func (event CellFlipped) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CellStateChanged) String() string {
+	return fmt.Sprintf("")
+}
+
+func (event CellStateChanged) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event TurnComplete) String() string {
 	return fmt.Sprintf("")
 }
@@ -122,6 +160,14 @@ func (event TurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event Notice) String() string {
+	return event.Message
+}
+
+func (event Notice) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event FinalTurnComplete) String() string {
 	return fmt.Sprintf("")
 }
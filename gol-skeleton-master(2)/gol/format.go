@@ -0,0 +1,230 @@
+package gol
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/colouring"
+	"uk.ac.bris.cs/gameoflife/engine"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// format reads and writes worlds in one kind of pattern file.
+type format struct {
+	name string
+	// extension is the file extension, without the dot, that selects this format.
+	extension string
+	// read parses a world from r. rule is the rule the file gives, if it gives one.
+	read func(r io.Reader, opts readOptions) (world *util.Grid, rule string, err error)
+	// givesRule is set if files in the format may give the rule of the pattern.
+	givesRule bool
+	// write saves world, which is simulated under rule, to w.
+	write func(w io.Writer, world *util.Grid, rule engine.Rule, opts writeOptions) error
+}
+
+// readOptions says how the cells of a world are read from a file.
+type readOptions struct {
+	// states is the number of cell states of the rule.
+	states int
+	// threshold is the fraction of white at or above which a grey pixel is an alive cell
+	// under a two-state rule. 0 means only white pixels are alive.
+	threshold float64
+}
+
+// writeOptions says how a world is drawn by the image formats that draw it in colour.
+type writeOptions struct {
+	// scale is the width and height in pixels of each cell; 0 is taken as 1.
+	scale   int
+	palette palette
+	// history colours cells by age or activity in place of the palette, if it is not nil.
+	history *history
+}
+
+func (o writeOptions) scaleOrOne() int {
+	if o.scale < 1 {
+		return 1
+	}
+	return o.scale
+}
+
+// formats is every supported format. Input files are looked for with each extension in
+// turn, so PGM images are preferred when several exist.
+var formats = []*format{pgmFormat, pbmFormat, pngFormat, rleFormat, cellsFormat}
+
+// formatNamed returns the format with the given name or extension; an empty name is PGM.
+func formatNamed(name string) (*format, error) {
+	if name == "" {
+		return pgmFormat, nil
+	}
+	for _, f := range formats {
+		if strings.EqualFold(name, f.name) || strings.EqualFold(name, f.extension) {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid format %q: want pgm, pbm, png, rle or cells", name)
+}
+
+// formatOf returns the format selected by the extension of filename, or nil if none is.
+func formatOf(filename string) *format {
+	ext := strings.TrimPrefix(filepath.Ext(filename), ".")
+	for _, f := range formats {
+		if ext != "" && strings.EqualFold(ext, f.extension) {
+			return f
+		}
+	}
+	return nil
+}
+
+// findInput returns the path and format of an input file. A path with a known extension
+// is taken as it is; otherwise the first format with a file at path plus its extension is
+// used, or fallback if there is a file at path itself.
+func findInput(path string, fallback *format) (string, *format, error) {
+	if f := formatOf(path); f != nil {
+		return path, f, nil
+	}
+	for _, f := range formats {
+		if _, err := os.Stat(path + "." + f.extension); err == nil {
+			return path + "." + f.extension, f, nil
+		}
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path, fallback, nil
+	}
+	return "", nil, fmt.Errorf("no pattern file at %s", path)
+}
+
+// inputPath is the path of the file the distributor loads for p: p.Input if it is set, and
+// otherwise the image in the images directory named after the size of the world.
+func inputPath(p Params) string {
+	if p.Input != "" {
+		return p.Input
+	}
+	return filepath.Join("images", fmt.Sprintf("%dx%d", p.ImageHeight, p.ImageWidth))
+}
+
+// readPattern reads the pattern file at path, found as findInput does, returning the
+// pattern and the rule the file gives.
+func readPattern(path string, fallback *format, opts readOptions) (*util.Grid, string, error) {
+	path, f, err := findInput(path, fallback)
+	if err != nil {
+		return nil, "", err
+	}
+	return readFile(path, f, opts)
+}
+
+// readFile reads the pattern file at path in format f, returning the pattern and the rule
+// the file gives.
+func readFile(path string, f *format, opts readOptions) (*util.Grid, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	pattern, rule, err := f.read(file, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %v", path, err)
+	}
+	return pattern, rule, nil
+}
+
+// Input is the input file of a run, read once before it starts: the pattern in it, which
+// gives its size, and the rule the world is simulated under. It also holds the other
+// parameters of how the run is read and written, checked as the file is read.
+type Input struct {
+	Path    string
+	Pattern *util.Grid
+	Rule    engine.Rule
+
+	format    *format
+	placement placement
+	palette   palette
+	mode      colouring.Mode
+}
+
+// ReadInput reads the input file for p. The rule is p.Rule if that is set, and otherwise
+// the one the file gives, or Conway's Life if it gives none. It also reports an error if
+// the Format, Placement, Palette or Colour of p is not valid.
+func ReadInput(p Params) (*Input, error) {
+	fallback, err := formatNamed(p.Format)
+	if err != nil {
+		return nil, err
+	}
+	where, err := parsePlacement(p.Placement)
+	if err != nil {
+		return nil, err
+	}
+	colours, err := parsePalette(p.Palette)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := colouring.ParseMode(p.Colour)
+	if err != nil {
+		return nil, err
+	}
+	path, f, err := findInput(inputPath(p), fallback)
+	if err != nil {
+		return nil, err
+	}
+	text := p.Rule
+	opts := readOptions{states: 2, threshold: p.Threshold}
+	if text != "" {
+		rule, err := engine.ParseRule(text)
+		if err != nil {
+			return nil, err
+		}
+		opts.states = rule.States
+	} else if f.givesRule {
+		// The number of states is only known once the file's rule is, so the pattern is
+		// read with room for any number and checked against the rule afterwards.
+		opts.states = 256
+	}
+	pattern, given, err := readFile(path, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	if text == "" {
+		text = given
+	}
+	rule, err := engine.ParseRule(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if rule.States != opts.states {
+		if pattern, err = restate(pattern, rule.States); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return &Input{Path: path, Pattern: pattern, Rule: rule,
+		format: fallback, placement: where, palette: colours, mode: mode}, nil
+}
+
+// restate returns pattern with room for the given number of states, or an error if any of
+// its cells are in a state past them.
+func restate(pattern *util.Grid, states int) (*util.Grid, error) {
+	restated := util.NewStateGrid(pattern.Width, pattern.Height, states)
+	for y := 0; y < pattern.Height; y++ {
+		for x := 0; x < pattern.Width; x++ {
+			state := pattern.State(x, y)
+			if int(state) >= states {
+				return nil, fmt.Errorf("pattern has cells in state %d, but the rule has %d states", state, states)
+			}
+			restated.SetState(x, y, state)
+		}
+	}
+	return restated, nil
+}
+
+// Fit returns p with a width or height of 0 replaced by that of the pattern, so the world
+// is made to fit it.
+func (in *Input) Fit(p Params) Params {
+	if p.ImageWidth == 0 {
+		p.ImageWidth = in.Pattern.Width
+	}
+	if p.ImageHeight == 0 {
+		p.ImageHeight = in.Pattern.Height
+	}
+	return p
+}
@@ -0,0 +1,94 @@
+package gol
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestReadInput tests that the rule of a run comes from the input file unless one is given,
+// and that a bad rule in the file, or a bad format, placement, palette or colouring, is
+// reported rather than ignored.
+func TestReadInput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		file    string
+		rule    string
+		want    string
+		pattern string
+	}{
+		{"no rule", "x = 3, y = 1\n3o!", "", "B3/S23", "111"},
+		{"file's rule", "x = 3, y = 1, rule = B36/S23\n3o!", "", "B36/S23", "111"},
+		{"given rule", "x = 3, y = 1, rule = B36/S23\n3o!", "B2/S", "B2/S", "111"},
+		{"generations", "x = 3, y = 1, rule = B2/S/C3\nAB.!", "", "B2/S/C3", "12."},
+		{"generations given", "x = 3, y = 1\nAB.!", "B2/S/C4", "B2/S/C4", "12."},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, "pattern.rle")
+		if err := ioutil.WriteFile(path, []byte(test.file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		input, err := ReadInput(Params{Input: path, Rule: test.rule})
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if input.Rule.String() != test.want {
+			t.Errorf("%s: rule %v, want %s", test.name, input.Rule, test.want)
+		}
+		if got := rows(input.Pattern); got != test.pattern {
+			t.Errorf("%s: read %s, want %s", test.name, got, test.pattern)
+		}
+		if bits := util.BitsFor(input.Rule.States); input.Pattern.CellBits() != bits {
+			t.Errorf("%s: pattern has %d bits a cell, want %d", test.name, input.Pattern.CellBits(), bits)
+		}
+	}
+
+	for _, test := range []struct {
+		name string
+		file string
+		rule string
+	}{
+		{"bad rule in file", "x = 3, y = 1, rule = B9/S23\n3o!", ""},
+		{"states past the file's rule", "x = 3, y = 1, rule = B2/S/C3\nAC.!", ""},
+		{"states past the given rule", "x = 3, y = 1, rule = B2/S/C4\nAB.!", "B3/S23"},
+		{"bad given rule", "x = 3, y = 1\n3o!", "nonsense"},
+	} {
+		path := filepath.Join(dir, "pattern.rle")
+		if err := ioutil.WriteFile(path, []byte(test.file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if input, err := ReadInput(Params{Input: path, Rule: test.rule}); err == nil {
+			t.Errorf("%s: read rule %v, want an error", test.name, input.Rule)
+		}
+	}
+	if _, err := ReadInput(Params{Input: filepath.Join(dir, "missing.rle")}); err == nil {
+		t.Error("read a missing input file")
+	}
+
+	path := filepath.Join(dir, "pattern.rle")
+	if err := ioutil.WriteFile(path, []byte("x = 3, y = 1\n3o!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []Params{
+		{Format: "bmp"},
+		{Placement: "middle"},
+		{Palette: "purple"},
+		{Palette: "000000,fffff"},
+		{Colour: "rainbow"},
+	} {
+		p.Input = path
+		if _, err := ReadInput(p); err == nil {
+			t.Errorf("read input with %+v, want an error", p)
+		}
+	}
+}
@@ -1,43 +1,119 @@
 package gol
 
+import (
+	"log"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
 	Turns       int
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	// Rule is a Life-like rule in B/S notation such as "B36/S23", or a name such as "highlife". If empty, Conway's B3/S23 is used.
+	Rule string
+	// Topology is how the edges of the world join: "torus", "plane" (cells beyond the edges are dead), "klein" or "cross". If empty, a torus is used.
+	Topology string
+	// Algorithm is "sparse" to step an unbounded world that the image is placed in, holding only its live cells, or "hashlife" to advance a square torus by many turns at a time. If empty, the world is the size of the image and is stepped one turn at a time.
+	Algorithm string
+	// Format is the format images are saved in: "pgm", "pbm", "png", "rle" (Golly run-length encoding) or "cells" (plaintext). If empty, PGM is used. Input files may be in any of these formats, chosen by their extension.
+	Format string
+	// Scale is the size in pixels of each cell in PNG images and recordings. If 0, each cell is one pixel.
+	Scale int
+	// Palette is the colours of PNG images and recordings: "grey", "paper", "green", "amber", or dead and alive cell colours in hex such as "000000,ffffff". If empty, grey is used.
+	Palette string
+	// Colour is how PNG images and recordings colour cells: "state", "age" since each was born or died, or "heat" for how often each has changed. If empty, cells are coloured by their state with the Palette.
+	Colour string
+	// Record makes an animated GIF of every Record-th turn, saved when the run ends. If 0, nothing is recorded.
+	Record int
+	// RecordFrames is the most frames a recording holds, as they are kept in memory until it is saved. Later frames are left out. If 0, 500 is used.
+	RecordFrames int
+	// Threshold is the fraction of white at or above which a grey pixel of a PGM image is an alive cell. If 0, only white pixels are alive. It is not used for Generations rules, whose dying states are read from grey levels.
+	Threshold float64
+	// Input is the path of the pattern file to load. If empty, images/<height>x<width> is loaded. A width or height of 0 is taken from the file.
+	Input string
+	// Placement is where a pattern smaller than the world goes: "corner" (top-left), "centre" or "tile" (repeated to fill the world). If empty, the corner is used.
+	Placement string
+	// OffsetX and OffsetY move the pattern right and down from its placement, wrapping around the edges of the world.
+	OffsetX int
+	OffsetY int
+	// OutputDir is the directory images are saved in. If empty, out is used.
+	OutputDir string
+	// OutputName names saved images, with {w}, {h} and {turn} replaced by the world's size and the turn and {input} by the input file's name. If empty, {w}x{h}x{turn} is used.
+	OutputName string
+	// Server is the host:port of the GoL worker or broker. If empty, $GOL_SERVER or 127.0.0.1:8030 is used.
+	Server string
+	// Engine selects LocalEngine or RemoteEngine. If empty, the remote engine is used only when a server is configured.
+	Engine string
+	// Session names a running session to reattach to. If empty, a session with matching parameters is reattached to if there is one.
+	Session string
+	// TurnsPerSecond limits how fast the world is stepped. If 0, it is stepped as fast as it can be.
+	TurnsPerSecond float64
+	// Until makes the run pause once that turn is reached, so it can be stepped through from there. If 0, it only pauses when p is pressed.
+	Until int
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
 func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+	RunEditable(p, events, keyPresses, nil)
+}
+
+// RunEditable is Run for a visualiser that lets the user draw on the world: each Edit
+// received on edits is made to the world, which carries on from there.
+func RunEditable(p Params, events chan<- Event, keyPresses <-chan rune, edits <-chan Edit) {
+	input, err := ReadInput(p)
+	if err != nil {
+		log.Fatal(err)
+	}
+	RunInput(p, input, events, keyPresses, edits)
+}
+
+// RunInput is RunEditable for an input file already read by ReadInput from the same p.
+func RunInput(p Params, input *Input, events chan<- Event, keyPresses <-chan rune, edits <-chan Edit) {
 
 	//	TODO: Put the missing channels in here.
 
+	// The world is the size of the input file unless a size is given.
+	p = input.Fit(p)
+	rule := input.Rule
+	p.Rule = rule.String()
+	cells := newHistory(p, input.mode, input.format)
+	opts := writeOptions{scale: p.Scale, palette: input.palette, history: cells}
+
 	ioCommand := make(chan ioCommand)
 	ioIdle := make(chan bool)
 
 	// make channels for filename output and input
 	ioFilename := make(chan string)
-	ioOutput := make(chan uint8, 1000)
-	ioInput := make(chan uint8)
+	ioOutput := make(chan *util.Grid)
+	ioInput := make(chan *util.Grid)
+	ioInputError := make(chan error)
+	ioWritten := make(chan error)
 
 	ioChannels := ioChannels{
-		command:  ioCommand,
-		idle:     ioIdle,
-		filename: ioFilename,
-		output:   ioOutput,
-		input:    ioInput,
+		command:    ioCommand,
+		idle:       ioIdle,
+		filename:   ioFilename,
+		output:     ioOutput,
+		input:      ioInput,
+		inputError: ioInputError,
+		written:    ioWritten,
 	}
-	go startIo(p, ioChannels)
+	go startIo(p, input, opts, ioChannels)
 
 	distributorChannels := distributorChannels{
-		events:     events,
-		ioCommand:  ioCommand,
-		ioIdle:     ioIdle,
-		ioFilename: ioFilename,
-		ioOutput:   ioOutput,
-		ioInput:    ioInput,
-		keyPresses: keyPresses,
+		events:       events,
+		ioCommand:    ioCommand,
+		ioIdle:       ioIdle,
+		ioFilename:   ioFilename,
+		ioOutput:     ioOutput,
+		ioInput:      ioInput,
+		ioInputError: ioInputError,
+		ioWritten:    ioWritten,
+		keyPresses:   keyPresses,
+		edits:        edits,
 	}
-	distributor(p, distributorChannels)
+	distributor(p, rule, cells, distributorChannels)
 }
@@ -0,0 +1,90 @@
+package gol
+
+import (
+	"image"
+	"image/color"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/colouring"
+	"uk.ac.bris.cs/gameoflife/engine"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// history is the history of every cell of the image, kept by relayFlips so that PNG images
+// and recordings can colour cells by age or by how often they have changed. The io
+// goroutine draws from it while relayFlips adds to it.
+type history struct {
+	mutex sync.Mutex
+	board *colouring.Board
+	mode  colouring.Mode
+}
+
+// newHistory returns the history for colouring cells in mode, or nil if they are coloured
+// by their state alone or neither images in format f nor recordings are drawn.
+func newHistory(p Params, mode colouring.Mode, f *format) *history {
+	if mode == colouring.State || (f != pngFormat && p.Record == 0) {
+		return nil
+	}
+	return &history{board: colouring.NewBoard(p.ImageWidth, p.ImageHeight), mode: mode}
+}
+
+// load starts the history afresh from world. Its cells are taken as set at turn 0, so they
+// count as neither newborn nor active, and the board is then moved on to turn.
+func (h *history) load(world *util.Grid, rule engine.Rule, turn int) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.board = colouring.NewBoard(h.board.Width, h.board.Height)
+	for y := 0; y < h.board.Height; y++ {
+		for x := 0; x < h.board.Width; x++ {
+			h.board.Set(util.Cell{X: x, Y: y}, util.StateGrey(world.StateAt(x, y), rule.States), 0)
+		}
+	}
+	h.board.Complete(turn)
+}
+
+// set records that cell entered state in the given turn.
+func (h *history) set(cell util.Cell, state uint8, rule engine.Rule, turn int) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.board.Set(cell, util.StateGrey(state, rule.States), turn)
+}
+
+// complete moves the history on to the given turn.
+func (h *history) complete(turn int) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.board.Complete(turn)
+}
+
+// draw draws world coloured by the history, each cell a scale by scale square. Cells of an
+// unbounded world outside the image have no history and are drawn by their state.
+func (h *history) draw(world *util.Grid, rule engine.Rule, scale int) *image.RGBA {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	img := image.NewRGBA(image.Rect(0, 0, world.Width*scale, world.Height*scale))
+	for y := 0; y < world.Height; y++ {
+		for x := 0; x < world.Width; x++ {
+			cell := util.Cell{X: x + world.OriginX, Y: y + world.OriginY}
+			grey := util.StateGrey(world.State(x, y), rule.States)
+			c := color.RGBA{grey, grey, grey, 255}
+			if h.board.Contains(cell) {
+				c.R, c.G, c.B = h.board.RGBAs(cell.X, cell.Y, grey, h.mode)
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetRGBA(x*scale+dx, y*scale+dy, c)
+				}
+			}
+		}
+	}
+	return img
+}
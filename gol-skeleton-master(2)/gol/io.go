@@ -2,10 +2,10 @@ package gol
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
+	"uk.ac.bris.cs/gameoflife/engine"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
@@ -14,17 +14,32 @@ type ioChannels struct {
 	idle    chan<- bool
 
 	filename <-chan string
-	output   <-chan uint8
-	input    chan<- uint8
+	output   <-chan *util.Grid
+	input    chan<- *util.Grid
+	// inputError is sent the error when an input file cannot be read.
+	inputError chan<- error
+	// written is sent the result of writing each image or recording: nil once it is
+	// written, or the error that stopped it.
+	written chan<- error
 }
 
 // ioState is the internal ioState of the io goroutine.
 type ioState struct {
-	params   Params
-	channels ioChannels
+	params Params
+	// rule decides how many grey levels the cells in an image have.
+	rule engine.Rule
+	// input is the input file, read before the run started.
+	input *Input
+	// format is the format images are written in.
+	format       *format
+	writeOptions writeOptions
+	placement    placement
+	channels     ioChannels
+	// recording holds the frames recorded so far, if any.
+	recording *recording
 }
 
-// ioCommand allows requesting behaviour from the io (pgm) goroutine.
+// ioCommand allows requesting behaviour from the io goroutine.
 type ioCommand uint8
 
 // This is a way of creating enums in Go.
@@ -32,104 +47,145 @@ type ioCommand uint8
 //		ioOutput 	= 0
 //		ioInput 	= 1
 //		ioCheckIdle = 2
+//		ioFrame		= 3
+//		ioRecording	= 4
 const (
 	ioOutput ioCommand = iota
 	ioInput
 	ioCheckIdle
+	ioFrame
+	ioRecording
 )
 
-// writePgmImage receives an array of bytes and writes it to a pgm file.
-func (io *ioState) writePgmImage() {
-	_ = os.Mkdir("out", os.ModePerm)
-
+// writeImage receives a packed world and writes it to a file in the output format.
+// The image is the size of the world, which for an unbounded world is its bounding box.
+func (io *ioState) writeImage() error {
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
+	world := <-io.channels.output
 
-	file, ioError := os.Create("out/" + filename + ".pgm")
-	util.Check(ioError)
-	defer file.Close()
-
-	_, _ = file.WriteString("P5\n")
-	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
-	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageHeight))
-	_, _ = file.WriteString("\n")
-	_, _ = file.WriteString(strconv.Itoa(255))
-	_, _ = file.WriteString("\n")
-
-	world := make([][]byte, io.params.ImageHeight)
-	for i := range world {
-		world[i] = make([]byte, io.params.ImageWidth)
+	err := io.writeFile(filename+"."+io.format.extension, func(file *os.File) error {
+		return io.format.write(file, world, io.rule, io.writeOptions)
+	})
+	if err != nil {
+		return err
 	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			val := <-io.channels.output
-			//if val != 0 {
-			//	fmt.Println(x, y)
-			//}
-			world[y][x] = val
-		}
-	}
+	fmt.Println("File", filename, "output done!")
+	return nil
+}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			_, ioError = file.Write([]byte{world[y][x]})
-			util.Check(ioError)
-		}
+// writeFile creates the file name in the output directory and writes it with write. If it
+// cannot be written in full, it is removed rather than left half written.
+func (io *ioState) writeFile(name string, write func(file *os.File) error) error {
+	dir := io.params.OutputDir
+	if dir == "" {
+		dir = "out"
 	}
-
-	ioError = file.Sync()
-	util.Check(ioError)
-
-	fmt.Println("File", filename, "output done!")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = write(file); err != nil {
+		err = fmt.Errorf("%s: %v", path, err)
+	} else {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
 }
 
-// readPgmImage opens a pgm file and sends its data as an array of bytes.
-func (io *ioState) readPgmImage() {
+// readImage sends the pattern from the input file as a packed world. A pattern smaller than
+// the world is placed in it as the params say. If it is larger, an error is sent instead.
+func (io *ioState) readImage() {
 
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
 
-	data, ioError := ioutil.ReadFile("images/" + filename + ".pgm")
-	util.Check(ioError)
+	pattern := io.input.Pattern
+	if pattern.Width > io.params.ImageWidth || pattern.Height > io.params.ImageHeight {
+		io.channels.inputError <- fmt.Errorf("%s is %dx%d, larger than the %dx%d world",
+			filename, pattern.Width, pattern.Height, io.params.ImageWidth, io.params.ImageHeight)
+		return
+	}
+	io.channels.input <- place(pattern, io.params, io.placement, io.rule.States)
 
-	fields := strings.Fields(string(data))
+	fmt.Println("File", filename, "input done!")
+}
 
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
+// place returns a world of the size p gives with pattern put in it where p says. Offsets
+// wrap around the edges of the world.
+func place(pattern *util.Grid, p Params, where placement, states int) *util.Grid {
+	width, height := p.ImageWidth, p.ImageHeight
+	world := util.NewStateGrid(width, height, states)
+	left, top := p.OffsetX, p.OffsetY
+	if where == placeCentre {
+		left += (width - pattern.Width) / 2
+		top += (height - pattern.Height) / 2
 	}
-
-	width, _ := strconv.Atoi(fields[1])
-	if width != io.params.ImageWidth {
-		panic("Incorrect width")
+	wrap := func(v, n int) int {
+		return (v%n + n) % n
 	}
-
-	height, _ := strconv.Atoi(fields[2])
-	if height != io.params.ImageHeight {
-		panic("Incorrect height")
+	if where == placeTile {
+		if pattern.Width == 0 || pattern.Height == 0 {
+			return world
+		}
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				world.SetState(x, y, pattern.State(wrap(x-left, pattern.Width), wrap(y-top, pattern.Height)))
+			}
+		}
+		return world
 	}
-
-	maxval, _ := strconv.Atoi(fields[3])
-	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
+	for y := 0; y < pattern.Height; y++ {
+		for x := 0; x < pattern.Width; x++ {
+			world.SetState(wrap(left+x, width), wrap(top+y, height), pattern.State(x, y))
+		}
 	}
+	return world
+}
 
-	image := []byte(fields[4])
+// placement says where in the world a smaller pattern is put.
+type placement uint8
 
-	for _, b := range image {
-		io.channels.input <- b
-	}
+const (
+	placeCorner placement = iota
+	placeCentre
+	placeTile
+)
 
-	fmt.Println("File", filename, "input done!")
+// parsePlacement parses Params.Placement; an empty string is the top-left corner.
+func parsePlacement(s string) (placement, error) {
+	switch strings.ToLower(s) {
+	case "", "corner":
+		return placeCorner, nil
+	case "centre", "center":
+		return placeCentre, nil
+	case "tile":
+		return placeTile, nil
+	}
+	return placeCorner, fmt.Errorf("invalid placement %q: want corner, centre or tile", s)
 }
 
 // startIo should be the entrypoint of the io goroutine.
-func startIo(p Params, c ioChannels) {
+func startIo(p Params, input *Input, opts writeOptions, c ioChannels) {
 	io := ioState{
-		params:   p,
-		channels: c,
+		params:       p,
+		input:        input,
+		rule:         input.Rule,
+		format:       input.format,
+		writeOptions: opts,
+		placement:    input.placement,
+		channels:     c,
 	}
 
 	for {
@@ -138,11 +194,15 @@ func startIo(p Params, c ioChannels) {
 		case command := <-io.channels.command:
 			switch command {
 			case ioInput:
-				io.readPgmImage()
+				io.readImage()
 			case ioOutput:
-				io.writePgmImage()
+				io.channels.written <- io.writeImage()
 			case ioCheckIdle:
 				io.channels.idle <- true
+			case ioFrame:
+				io.recordFrame()
+			case ioRecording:
+				io.channels.written <- io.writeRecording()
 			}
 		}
 	}
@@ -0,0 +1,143 @@
+package gol
+
+import (
+	"errors"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestWriteFileError tests that a file that cannot be written in full is removed and its
+// error returned.
+func TestWriteFileError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	io := &ioState{params: Params{OutputDir: dir}}
+	full := errors.New("no space left on device")
+	err = io.writeFile("image.pgm", func(file *os.File) error {
+		file.WriteString("P5 2 2 255\n")
+		return full
+	})
+	if err == nil {
+		t.Fatal("writeFile returned no error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "image.pgm")); !os.IsNotExist(err) {
+		t.Errorf("half written file left behind: %v", err)
+	}
+}
+
+// TestOutputError tests that a run whose images and recording cannot be saved carries on
+// to the end, reporting no image as saved.
+func TestOutputError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The output directory cannot be made inside a file.
+	blocked := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := Params{Input: "../images/16x16.pgm", ImageWidth: 16, ImageHeight: 16, Turns: 10, Threads: 2,
+		Record: 2, Engine: LocalEngine, OutputDir: filepath.Join(blocked, "out")}
+	events := make(chan Event)
+	go Run(p, events, nil)
+	var final *FinalTurnComplete
+	for event := range events {
+		switch e := event.(type) {
+		case ImageOutputComplete:
+			t.Errorf("%s reported as saved", e.Filename)
+		case FinalTurnComplete:
+			final = &e
+		}
+	}
+	if final == nil || final.CompletedTurns != 10 {
+		t.Fatalf("run ended with %v, want turn 10 completed", final)
+	}
+}
+
+// TestRecordingFull tests that a recording that reaches its frame limit is reported with a
+// Notice during the run, and still saved with the frames up to the limit.
+func TestRecordingFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := Params{Input: "../images/16x16.pgm", ImageWidth: 16, ImageHeight: 16, Turns: 10, Threads: 2,
+		Record: 1, RecordFrames: 3, Engine: LocalEngine, OutputDir: dir}
+	events := make(chan Event)
+	go Run(p, events, nil)
+	var notices []Notice
+	saved := false
+	for event := range events {
+		switch e := event.(type) {
+		case Notice:
+			notices = append(notices, e)
+		case ImageOutputComplete:
+			saved = saved || e.Filename == "16x16x10"
+		}
+	}
+	// Turns 0, 1 and 2 are recorded, so turn 3 is the first left out.
+	if len(notices) != 1 || notices[0].CompletedTurns != 3 {
+		t.Errorf("got notices %v, want one that the recording was full at turn 3", notices)
+	}
+	if !saved {
+		t.Fatal("recording not reported as saved")
+	}
+	file, err := os.Open(filepath.Join(dir, "16x16x10.gif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	recorded, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded.Image) != 3 {
+		t.Errorf("recording has %d frames, want 3", len(recorded.Image))
+	}
+}
+
+// TestPlace tests that a pattern is put in the corner, the centre or tiled over the world,
+// moved by the offsets and wrapped around the edges.
+func TestPlace(t *testing.T) {
+	// The pattern is 1. over .2, so that its placement can be told in either direction.
+	pattern := util.NewStateGrid(2, 2, 3)
+	pattern.SetState(0, 0, 1)
+	pattern.SetState(1, 1, 2)
+	for _, test := range []struct {
+		name             string
+		placement        string
+		offsetX, offsetY int
+		want             string
+	}{
+		{"corner", "", 0, 0, "1.../.2../...."},
+		{"corner wrapped", "corner", 3, 2, "2.../..../...1"},
+		{"centre", "centre", 0, 0, ".1../..2./...."},
+		{"centre wrapped", "centre", 2, 1, "..../...1/2..."},
+		{"tile", "tile", 0, 0, "1.1./.2.2/1.1."},
+		{"tile moved", "tile", 1, 1, "2.2./.1.1/2.2."},
+		{"tile moved back", "tile", -1, -3, "2.2./.1.1/2.2."},
+	} {
+		where, err := parsePlacement(test.placement)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		p := Params{ImageWidth: 4, ImageHeight: 3, OffsetX: test.offsetX, OffsetY: test.offsetY}
+		if got := rows(place(pattern, p, where, 3)); got != test.want {
+			t.Errorf("%s: placed %s, want %s", test.name, got, test.want)
+		}
+	}
+}
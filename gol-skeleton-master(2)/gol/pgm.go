@@ -0,0 +1,86 @@
+package gol
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"uk.ac.bris.cs/gameoflife/engine"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// pgmFormat is PGM, one grey level per cell, where white is alive. Under a Generations
+// rule other grey levels that are not black are dying cells. It is saved as binary P5 with a
+// maxval of 255; any PBM or PGM image can be read, whatever its maxval.
+var pgmFormat = &format{name: "pgm", extension: "pgm", read: readPnm, write: writePgm}
+
+// pbmFormat is binary PBM, one bit per cell. As in PGM images alive cells are white, so
+// they are 0 bits.
+var pbmFormat = &format{name: "pbm", extension: "pbm", read: readPnm, write: writePbm}
+
+func readPnm(r io.Reader, opts readOptions) (*util.Grid, string, error) {
+	image, err := util.ReadPNM(r)
+	if err != nil {
+		return nil, "", err
+	}
+	world := util.NewStateGrid(image.Width, image.Height, opts.states)
+	for i, pixel := range image.Pixels {
+		world.SetState(i%image.Width, i/image.Width, pixelState(pixel, image.MaxVal, opts))
+	}
+	return world, "", nil
+}
+
+// pixelState returns the state of the cell shown by a pixel of an image with the given maxval.
+func pixelState(pixel uint16, maxval int, opts readOptions) uint8 {
+	if opts.states > 2 {
+		grey := (int(pixel)*255 + maxval/2) / maxval
+		return util.GreyState(uint8(grey), opts.states)
+	}
+	alive := int(pixel) == maxval
+	if opts.threshold > 0 {
+		alive = float64(pixel) >= opts.threshold*float64(maxval)
+	}
+	if alive {
+		return 1
+	}
+	return 0
+}
+
+func writePgm(w io.Writer, world *util.Grid, rule engine.Rule, opts writeOptions) error {
+	_, _ = io.WriteString(w, "P5\n")
+	//_, _ = io.WriteString(w, "# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
+	_, _ = io.WriteString(w, strconv.Itoa(world.Width))
+	_, _ = io.WriteString(w, " ")
+	_, _ = io.WriteString(w, strconv.Itoa(world.Height))
+	_, _ = io.WriteString(w, "\n")
+	_, _ = io.WriteString(w, strconv.Itoa(255))
+	_, _ = io.WriteString(w, "\n")
+
+	pixels := make([]byte, world.Width*world.Height)
+	for y := 0; y < world.Height; y++ {
+		for x := 0; x < world.Width; x++ {
+			pixels[y*world.Width+x] = util.StateGrey(world.State(x, y), rule.States)
+		}
+	}
+	_, err := w.Write(pixels)
+	return err
+}
+
+func writePbm(w io.Writer, world *util.Grid, rule engine.Rule, opts writeOptions) error {
+	_, _ = fmt.Fprintf(w, "P4\n%d %d\n", world.Width, world.Height)
+	row := make([]byte, (world.Width+7)/8)
+	for y := 0; y < world.Height; y++ {
+		for i := range row {
+			row[i] = 0
+		}
+		for x := 0; x < world.Width; x++ {
+			if !world.Alive(x, y) {
+				row[x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
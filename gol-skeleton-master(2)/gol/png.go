@@ -0,0 +1,146 @@
+package gol
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	imagepalette "image/color/palette"
+	"image/draw"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/engine"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// pngFormat is PNG, drawn with the palette or colouring and the scale of the writeOptions.
+// When read, each pixel is a cell and its brightness decides the cell's state as for PGM
+// images.
+var pngFormat = &format{name: "png", extension: "png", read: readPng, write: writePng}
+
+// palette is the colours cells are drawn in. Dying cells fade from alive to dead.
+type palette struct {
+	dead  color.RGBA
+	alive color.RGBA
+}
+
+// namedPalettes are the palettes that may be given by name.
+var namedPalettes = map[string]palette{
+	"grey":  {dead: color.RGBA{0, 0, 0, 255}, alive: color.RGBA{255, 255, 255, 255}},
+	"paper": {dead: color.RGBA{255, 255, 255, 255}, alive: color.RGBA{0, 0, 0, 255}},
+	"green": {dead: color.RGBA{0, 24, 0, 255}, alive: color.RGBA{64, 255, 64, 255}},
+	"amber": {dead: color.RGBA{24, 12, 0, 255}, alive: color.RGBA{255, 176, 0, 255}},
+}
+
+// parsePalette parses a palette name, or two hex colours for dead and alive cells such as
+// "000000,ffffff". An empty string is grey, as in PGM images.
+func parsePalette(s string) (palette, error) {
+	if s == "" {
+		return namedPalettes["grey"], nil
+	}
+	if p, ok := namedPalettes[strings.ToLower(s)]; ok {
+		return p, nil
+	}
+	invalid := fmt.Errorf("invalid palette %q: want grey, paper, green, amber, or dead,alive hex colours like 000000,ffffff", s)
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return palette{}, invalid
+	}
+	var colours [2]color.RGBA
+	for i, part := range parts {
+		v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(part), "#"), 16, 32)
+		if err != nil || len(strings.TrimPrefix(strings.TrimSpace(part), "#")) != 6 {
+			return palette{}, invalid
+		}
+		colours[i] = color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}
+	}
+	return palette{dead: colours[0], alive: colours[1]}, nil
+}
+
+// colour returns the colour of a cell in state under a rule with the given number of states.
+func (p palette) colour(state uint8, states int) color.RGBA {
+	grey := int(util.StateGrey(state, states))
+	mix := func(dead, alive uint8) uint8 {
+		return uint8((int(dead)*(255-grey) + int(alive)*grey) / 255)
+	}
+	return color.RGBA{
+		mix(p.dead.R, p.alive.R),
+		mix(p.dead.G, p.alive.G),
+		mix(p.dead.B, p.alive.B),
+		255,
+	}
+}
+
+// colours returns the colour of every state, indexed by state.
+func (p palette) colours(states int) color.Palette {
+	colours := make(color.Palette, states)
+	for state := range colours {
+		colours[state] = p.colour(uint8(state), states)
+	}
+	return colours
+}
+
+// drawWorld draws world as an image, each cell a scale by scale square. Cells are
+// coloured by the history of opts if it has one, and otherwise drawn with a palette entry
+// for each state.
+func drawWorld(world *util.Grid, rule engine.Rule, opts writeOptions) image.Image {
+	if opts.history != nil {
+		return opts.history.draw(world, rule, opts.scaleOrOne())
+	}
+	return drawStates(world, rule, opts)
+}
+
+// drawFrame draws world as drawWorld does for a frame of a GIF recording, whose images
+// need a palette. Colours from the history are matched to the nearest in a fixed palette.
+func drawFrame(world *util.Grid, rule engine.Rule, opts writeOptions) *image.Paletted {
+	if opts.history == nil {
+		return drawStates(world, rule, opts)
+	}
+	coloured := opts.history.draw(world, rule, opts.scaleOrOne())
+	img := image.NewPaletted(coloured.Bounds(), imagepalette.Plan9)
+	draw.Draw(img, img.Bounds(), coloured, image.Point{}, draw.Src)
+	return img
+}
+
+// drawStates draws world with a palette entry for each state.
+func drawStates(world *util.Grid, rule engine.Rule, opts writeOptions) *image.Paletted {
+	scale := opts.scaleOrOne()
+	img := image.NewPaletted(image.Rect(0, 0, world.Width*scale, world.Height*scale), opts.palette.colours(rule.States))
+	for y := 0; y < world.Height; y++ {
+		for x := 0; x < world.Width; x++ {
+			state := world.State(x, y)
+			if state == 0 {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				row := img.Pix[(y*scale+dy)*img.Stride:]
+				for dx := 0; dx < scale; dx++ {
+					row[x*scale+dx] = state
+				}
+			}
+		}
+	}
+	return img
+}
+
+func writePng(w io.Writer, world *util.Grid, rule engine.Rule, opts writeOptions) error {
+	return png.Encode(w, drawWorld(world, rule, opts))
+}
+
+func readPng(r io.Reader, opts readOptions) (*util.Grid, string, error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, "", err
+	}
+	bounds := img.Bounds()
+	world := util.NewStateGrid(bounds.Dx(), bounds.Dy(), opts.states)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			grey := color.Gray16Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray16)
+			world.SetState(x, y, pixelState(grey.Y, 65535, opts))
+		}
+	}
+	return world, "", nil
+}
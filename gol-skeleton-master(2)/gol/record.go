@@ -0,0 +1,81 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"image/gif"
+	"os"
+)
+
+const (
+	// maxFrames is the most frames a recording holds if Params.RecordFrames does not say,
+	// as they are kept in memory until the animation is written.
+	maxFrames = 500
+	// frameDelay is how long each frame of a recording is shown, in hundredths of a second.
+	frameDelay = 10
+)
+
+// recording is an animated GIF built up a frame at a time by the io goroutine.
+type recording struct {
+	gif.GIF
+	// dropped counts the frames left out once the frame limit was reached.
+	dropped int
+}
+
+// frameLimit returns the most frames a recording for p holds.
+func frameLimit(p Params) int {
+	if p.RecordFrames > 0 {
+		return p.RecordFrames
+	}
+	return maxFrames
+}
+
+// framesDropped is the error from writing a recording that reached its frame limit. The
+// frames up to the limit are still written.
+type framesDropped struct {
+	limit, dropped int
+}
+
+func (e framesDropped) Error() string {
+	return fmt.Sprintf("stopped at %d frames, leaving out %d", e.limit, e.dropped)
+}
+
+// recordFrame receives a packed world and adds it to the recording as the next frame.
+func (io *ioState) recordFrame() {
+	world := <-io.channels.output
+	if io.recording == nil {
+		io.recording = &recording{}
+	}
+	if len(io.recording.Image) >= frameLimit(io.params) {
+		io.recording.dropped++
+		return
+	}
+	io.recording.Image = append(io.recording.Image, drawFrame(world, io.rule, io.writeOptions))
+	io.recording.Delay = append(io.recording.Delay, frameDelay)
+}
+
+// writeRecording writes the frames recorded so far to a GIF file and starts a new recording.
+// If frames were left out, it returns framesDropped once the file is written.
+func (io *ioState) writeRecording() error {
+	// Request a filename from the distributor.
+	filename := <-io.channels.filename
+
+	recorded := io.recording
+	io.recording = nil
+	if recorded == nil || len(recorded.Image) == 0 {
+		return errors.New("no frames were recorded")
+	}
+
+	err := io.writeFile(filename+".gif", func(file *os.File) error {
+		return gif.EncodeAll(file, &recorded.GIF)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("File", filename, "output done!")
+	if recorded.dropped > 0 {
+		return framesDropped{limit: len(recorded.Image), dropped: recorded.dropped}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package gol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/engine"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// rleFormat is Golly's run-length encoding, as used by the LifeWiki pattern collection.
+// The header line gives the size of the pattern and its rule. Two-state patterns use b for
+// dead cells and o for alive ones; Generations patterns use . for dead and A, B, ... for
+// states 1, 2, ..., with a prefix from p to y for states past 24.
+var rleFormat = &format{name: "rle", extension: "rle", read: readRle, write: writeRle, givesRule: true}
+
+// rleLineLength is the longest line writeRle writes, as Golly does.
+const rleLineLength = 70
+
+func readRle(r io.Reader, opts readOptions) (*util.Grid, string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+	states := opts.states
+	var world *util.Grid
+	rule := ""
+	x, y := 0, 0
+	run := 0
+	prefix := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		// Comment lines may come before or after the header, and # is never part of the data.
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if world == nil {
+			if line == "" {
+				continue
+			}
+			width, height, r, err := parseRleHeader(line)
+			if err != nil {
+				return nil, "", err
+			}
+			world = util.NewStateGrid(width, height, states)
+			rule = r
+			continue
+		}
+		for _, c := range line {
+			var state int
+			n := run
+			if n == 0 {
+				n = 1
+			}
+			switch {
+			case c >= '0' && c <= '9':
+				run = run*10 + int(c-'0')
+				continue
+			case c >= 'p' && c <= 'y':
+				prefix = int(c-'p') + 1
+				continue
+			case c == '!':
+				return world, rule, nil
+			case c == '$':
+				y += n
+				x, run = 0, 0
+				continue
+			case c == ' ' || c == '\t' || c == '\r':
+				continue
+			case c == 'b' || c == '.':
+				state = 0
+			case c == 'o':
+				state = 1
+			case c >= 'A' && c <= 'X':
+				state = prefix*24 + int(c-'A') + 1
+			default:
+				return nil, "", fmt.Errorf("invalid character %q in rle pattern", c)
+			}
+			if state >= states {
+				return nil, "", fmt.Errorf("rle pattern has cells in state %d, but the rule has %d states", state, states)
+			}
+			if x+n > world.Width || y >= world.Height {
+				return nil, "", fmt.Errorf("rle pattern runs past its %dx%d size", world.Width, world.Height)
+			}
+			for ; n > 0; n-- {
+				world.SetState(x, y, uint8(state))
+				x++
+			}
+			run, prefix = 0, 0
+		}
+	}
+	if world == nil {
+		return nil, "", errors.New("rle pattern has no header line")
+	}
+	return world, rule, nil
+}
+
+// rleHeaderKey matches the keys of a header line, with the equals sign after them.
+var rleHeaderKey = regexp.MustCompile(`(?i)(^|,)\s*(x|y|rule)\s*=`)
+
+// parseRleHeader parses a header line such as "x = 3, y = 3, rule = B3/S23". Each value
+// runs up to the comma before the next key, so a rule may itself hold commas, as in the
+// bounded grid suffix of "B3/S23:T100,100". The size of the world is set separately, so
+// such a suffix is left out of the rule returned.
+func parseRleHeader(line string) (width, height int, rule string, err error) {
+	invalid := fmt.Errorf("invalid rle header %q", line)
+	keys := rleHeaderKey.FindAllStringSubmatchIndex(line, -1)
+	if len(keys) == 0 || keys[0][0] != 0 {
+		return 0, 0, "", invalid
+	}
+	width, height = -1, -1
+	seen := make(map[string]bool)
+	for i, key := range keys {
+		name := strings.ToLower(line[key[4]:key[5]])
+		end := len(line)
+		if i+1 < len(keys) {
+			end = keys[i+1][0]
+		}
+		value := strings.TrimSpace(line[key[1]:end])
+		if seen[name] {
+			return 0, 0, "", invalid
+		}
+		seen[name] = true
+		switch name {
+		case "x":
+			width, err = strconv.Atoi(value)
+		case "y":
+			height, err = strconv.Atoi(value)
+		case "rule":
+			rule = value
+			if i := strings.Index(rule, ":"); i >= 0 {
+				rule = strings.TrimSpace(rule[:i])
+			}
+		}
+		if err != nil {
+			return 0, 0, "", invalid
+		}
+	}
+	if width < 0 || height < 0 {
+		return 0, 0, "", fmt.Errorf("rle header %q does not give the pattern's size", line)
+	}
+	return width, height, rule, nil
+}
+
+func writeRle(w io.Writer, world *util.Grid, rule engine.Rule, opts writeOptions) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "x = %d, y = %d, rule = %v\n", world.Width, world.Height, rule)
+	line := 0
+	emit := func(n int, tag string) {
+		item := tag
+		if n > 1 {
+			item = strconv.Itoa(n) + tag
+		}
+		if line+len(item) > rleLineLength {
+			out.WriteString("\n")
+			line = 0
+		}
+		out.WriteString(item)
+		line += len(item)
+	}
+	rows := 0
+	for y := 0; y < world.Height; y++ {
+		x := 0
+		for x < world.Width {
+			state := world.State(x, y)
+			n := 1
+			for x+n < world.Width && world.State(x+n, y) == state {
+				n++
+			}
+			// Dead cells at the end of a row are left out.
+			if state == 0 && x+n == world.Width {
+				break
+			}
+			if rows > 0 {
+				emit(rows, "$")
+				rows = 0
+			}
+			emit(n, rleTag(state, rule.States))
+			x += n
+		}
+		rows++
+	}
+	emit(1, "!")
+	out.WriteString("\n")
+	return out.Flush()
+}
+
+// rleTag returns the characters that stand for state in a pattern with the given number of states.
+func rleTag(state uint8, states int) string {
+	if states <= 2 {
+		if state == 0 {
+			return "b"
+		}
+		return "o"
+	}
+	if state == 0 {
+		return "."
+	}
+	tag := string(rune('A' + (int(state)-1)%24))
+	if state > 24 {
+		tag = string(rune('p'+(int(state)-1)/24-1)) + tag
+	}
+	return tag
+}
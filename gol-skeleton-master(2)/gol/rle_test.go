@@ -0,0 +1,223 @@
+package gol
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/engine"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+func TestParseRleHeader(t *testing.T) {
+	tests := []struct {
+		line          string
+		width, height int
+		rule          string
+	}{
+		{"x = 3, y = 2, rule = B3/S23", 3, 2, "B3/S23"},
+		{"x=3,y=2", 3, 2, ""},
+		{"X = 3 , Y = 2 , RULE = b36/s23", 3, 2, "b36/s23"},
+		{"x = 5, y = 5, rule = B3/S23:T100,100", 5, 5, "B3/S23"},
+		{"x = 5, y = 5, rule = B3/S23:P20,30, ", 5, 5, "B3/S23"},
+		{"x = 0, y = 0, rule = 23/3", 0, 0, "23/3"},
+	}
+	for _, test := range tests {
+		width, height, rule, err := parseRleHeader(test.line)
+		if err != nil {
+			t.Errorf("parseRleHeader(%q): %v", test.line, err)
+			continue
+		}
+		if width != test.width || height != test.height || rule != test.rule {
+			t.Errorf("parseRleHeader(%q) = %d, %d, %q, want %d, %d, %q",
+				test.line, width, height, rule, test.width, test.height, test.rule)
+		}
+	}
+	for _, line := range []string{
+		"",
+		"3o$",
+		"x = 3",
+		"y = 3, rule = B3/S23",
+		"x = a, y = 3",
+		"x = 3, y = 3, x = 4",
+		"x = 3, y = 3, foo = 1",
+		"size x = 3, y = 3",
+		"x = -1, y = 3",
+	} {
+		if width, height, rule, err := parseRleHeader(line); err == nil {
+			t.Errorf("parseRleHeader(%q) = %d, %d, %q, want an error", line, width, height, rule)
+		}
+	}
+}
+
+// rows returns world as one string a row, with . for dead cells and the state otherwise.
+func rows(world *util.Grid) string {
+	var b strings.Builder
+	for y := 0; y < world.Height; y++ {
+		if y > 0 {
+			b.WriteString("/")
+		}
+		for x := 0; x < world.Width; x++ {
+			if state := world.State(x, y); state == 0 {
+				b.WriteString(".")
+			} else {
+				b.WriteByte('0' + state)
+			}
+		}
+	}
+	return b.String()
+}
+
+func TestReadRle(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		rule  string
+		want  string
+	}{
+		{"glider", "#N Glider\nx = 3, y = 3, rule = B3/S23\nbo$2bo$3o!\n",
+			"B3/S23", ".1./..1/111"},
+		{"comments after header", "#N Glider\nx = 3, y = 3\n#C found in 1969\n#O John Conway\nbo$2bo$\n#C more\n3o!",
+			"", ".1./..1/111"},
+		{"long runs", "x = 12, y = 1\n10bo!", "", "..........1."},
+		{"long run of alive", "x = 12, y = 1\n12o!", "", "111111111111"},
+		{"rows skipped", "x = 2, y = 5\no2$bo3$!", "", "1./../.1/../.."},
+		{"rows skipped at once", "x = 1, y = 12\no11$o!", "", "1/././././././././././1"},
+		{"across lines", "x = 4, y = 2\n2o\nbo$\n4o!", "", "11.1/1111"},
+		{"after end", "x = 2, y = 1\nbo!\nthis is ignored", "", ".1"},
+		{"no end", "x = 2, y = 2\nbo$o\n", "", ".1/1."},
+		{"rule with suffix", "x = 2, y = 1, rule = B36/S23:T2,1\n2o!", "B36/S23", "11"},
+		{"generations", "x = 3, y = 1, rule = B2/S/C3\nAB.!", "B2/S/C3", "12."},
+		{"blank lines", "\n\nx = 1, y = 1\n\no!", "", "1"},
+	}
+	for _, test := range tests {
+		world, rule, err := readRle(strings.NewReader(test.input), readOptions{states: 3})
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if rule != test.rule {
+			t.Errorf("%s: rule %q, want %q", test.name, rule, test.rule)
+		}
+		if got := rows(world); got != test.want {
+			t.Errorf("%s: read %s, want %s", test.name, got, test.want)
+		}
+	}
+	for _, input := range []string{
+		"",
+		"#C only a comment\n",
+		"bo$2bo$3o!",
+		"x = 2, y = 1\n3o!",
+		"x = 2, y = 1\no$o!",
+		"x = 2, y = 1\nbz!",
+		"x = 2, y = 1\nC!",
+	} {
+		if world, _, err := readRle(strings.NewReader(input), readOptions{states: 3}); err == nil {
+			t.Errorf("readRle(%q) = %s, want an error", input, rows(world))
+		}
+	}
+}
+
+// randomWorld returns a width by height world of cells in random states below states.
+func randomWorld(width, height, states int, random *rand.Rand) *util.Grid {
+	world := util.NewStateGrid(width, height, states)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// Mostly dead cells, with runs of every length.
+			if random.Intn(3) == 0 {
+				world.SetState(x, y, uint8(1+random.Intn(states-1)))
+			}
+		}
+	}
+	return world
+}
+
+// TestRleRoundTrip tests that worlds written as RLE read back the same, including long
+// runs, empty rows and Generations states that need a prefix.
+func TestRleRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, text := range []string{"B3/S23", "B2/S345/C4", "B2/S/C60"} {
+		rule, err := engine.ParseRule(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		worlds := []*util.Grid{
+			util.NewStateGrid(40, 3, rule.States),
+			randomWorld(90, 30, rule.States, random),
+		}
+		full := util.NewStateGrid(75, 4, rule.States)
+		for x := 0; x < 75; x++ {
+			full.SetState(x, 2, uint8(rule.States-1))
+		}
+		worlds = append(worlds, full)
+		for _, world := range worlds {
+			var buf bytes.Buffer
+			if err := writeRle(&buf, world, rule, writeOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if len(line) > rleLineLength {
+					t.Errorf("%s: line of %d characters written", text, len(line))
+				}
+			}
+			read, readRule, err := readRle(&buf, readOptions{states: rule.States})
+			if err != nil {
+				t.Fatalf("%s: %v", text, err)
+			}
+			if readRule != rule.String() {
+				t.Errorf("%s: read rule %q, want %q", text, readRule, rule.String())
+			}
+			if read.Width != world.Width || read.Height != world.Height || rows(read) != rows(world) {
+				t.Errorf("%s: %dx%d world read back as %dx%d\n%s\nwant\n%s",
+					text, world.Width, world.Height, read.Width, read.Height, rows(read), rows(world))
+			}
+		}
+	}
+}
+
+func TestReadCells(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"glider", "!Name: Glider\n.O.\n..O\nOOO\n", ".1./..1/111"},
+		{"short rows", "!Name: Blinker\nOOO\n\n.O", "111/.../.1."},
+		{"stars and crlf", "*.*\r\n.*.\r\n", "1.1/.1."},
+		{"comments between rows", "O.\n!middle\n.O", "1./.1"},
+		{"empty", "!Nothing\n", ""},
+	}
+	for _, test := range tests {
+		world, _, err := readCells(strings.NewReader(test.input), readOptions{states: 2})
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := rows(world); got != test.want {
+			t.Errorf("%s: read %s, want %s", test.name, got, test.want)
+		}
+	}
+	if _, _, err := readCells(strings.NewReader(".O\nbo"), readOptions{states: 2}); err == nil {
+		t.Error("readCells read an invalid character")
+	}
+}
+
+// TestCellsRoundTrip tests that two-state worlds written as plaintext read back the same.
+func TestCellsRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	rule, _ := engine.ParseRule("")
+	for _, world := range []*util.Grid{randomWorld(37, 11, 2, random), util.NewGrid(3, 3)} {
+		var buf bytes.Buffer
+		if err := writeCells(&buf, world, rule, writeOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		read, _, err := readCells(&buf, readOptions{states: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read.Width != world.Width || read.Height != world.Height || rows(read) != rows(world) {
+			t.Errorf("%dx%d world read back as %dx%d", world.Width, world.Height, read.Width, read.Height)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Package fixture reads the expected worlds in check/images for the tests of the engine
+// and the broker, and compares worlds against them.
+package fixture
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// images is the check/images directory, found from this file so that tests in any package
+// can read it.
+var images = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "check", "images")
+}()
+
+// World reads the expected world of the given size after the given turns.
+func World(t *testing.T, size, turns int) *util.Grid {
+	t.Helper()
+	file, err := os.Open(filepath.Join(images, fmt.Sprintf("%dx%dx%d.pgm", size, size, turns)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	image, err := util.ReadPNM(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := util.NewGrid(image.Width, image.Height)
+	for i, grey := range image.Pixels {
+		world.Set(i%image.Width, i/image.Width, int(grey) == image.MaxVal)
+	}
+	return world
+}
+
+// AssertSameWorld fails the test if the states of any cells of given and expected differ.
+func AssertSameWorld(t *testing.T, given, expected *util.Grid, name string) {
+	t.Helper()
+	if given == nil || given.Width != expected.Width || given.Height != expected.Height || len(given.Rows) != expected.Height {
+		t.Fatalf("%s: world is not %dx%d", name, expected.Width, expected.Height)
+	}
+	for y := 0; y < expected.Height; y++ {
+		for x := 0; x < expected.Width; x++ {
+			if given.State(x, y) != expected.State(x, y) {
+				t.Fatalf("%s: cell (%d, %d) is %d, want %d", name, x, y, given.State(x, y), expected.State(x, y))
+			}
+		}
+	}
+}
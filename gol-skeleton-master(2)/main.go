@@ -3,10 +3,14 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 	"runtime"
 
+	"uk.ac.bris.cs/gameoflife/colouring"
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/recorder"
 	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/tui"
 )
 
 // main is the function called when starting Game of Life with 'go run .'
@@ -38,28 +42,239 @@ func main() {
 		10000000000,
 		"Specify the number of turns to process. Defaults to 10000000000.")
 
+	flag.StringVar(
+		&params.Rule,
+		"rule",
+		"",
+		"Specify a Life-like rule in B/S notation, e.g. B36/S23, or by name, e.g. highlife. Defaults to B3/S23.")
+
+	flag.StringVar(
+		&params.Topology,
+		"topology",
+		"",
+		"Specify how the edges of the world join: torus, plane, klein or cross. Defaults to torus.")
+
+	flag.StringVar(
+		&params.Algorithm,
+		"algorithm",
+		"",
+		"Specify sparse to run an unbounded world that only stores live cells, or hashlife to skip many turns at a time. Defaults to stepping a fixed-size world.")
+
+	flag.StringVar(
+		&params.Format,
+		"format",
+		"",
+		"Specify the format images are saved in: pgm, pbm, png, rle or cells. Defaults to pgm.")
+
+	flag.IntVar(
+		&params.Scale,
+		"scale",
+		1,
+		"Specify the size in pixels of each cell in PNG images and recordings. Defaults to 1.")
+
+	flag.StringVar(
+		&params.Palette,
+		"palette",
+		"",
+		"Specify the colours of PNG images and recordings: grey, paper, green, amber, or dead,alive hex colours. Defaults to grey.")
+
+	flag.IntVar(
+		&params.Record,
+		"record",
+		0,
+		"Specify N to record every Nth turn into an animated GIF, saved when the run ends. Defaults to no recording.")
+
+	flag.IntVar(
+		&params.RecordFrames,
+		"recordFrames",
+		0,
+		"Specify the most frames a recording holds, as they are kept in memory until it is saved. Defaults to 500.")
+
+	flag.Float64Var(
+		&params.Threshold,
+		"threshold",
+		0,
+		"Specify the fraction of white at which a grey pixel of an input image is alive, e.g. 0.5. Defaults to only white pixels.")
+
+	flag.StringVar(
+		&params.Input,
+		"input",
+		"",
+		"Specify the pattern file to load. Its size is used unless -w or -h is given. Defaults to images/<h>x<w>.pgm.")
+
+	flag.StringVar(
+		&params.Placement,
+		"place",
+		"",
+		"Specify where a pattern smaller than the world goes: corner, centre or tile. Defaults to corner.")
+
+	flag.IntVar(
+		&params.OffsetX,
+		"offsetX",
+		0,
+		"Specify how far right to move the pattern from its placement. Defaults to 0.")
+
+	flag.IntVar(
+		&params.OffsetY,
+		"offsetY",
+		0,
+		"Specify how far down to move the pattern from its placement. Defaults to 0.")
+
+	flag.StringVar(
+		&params.OutputDir,
+		"out",
+		"out",
+		"Specify the directory images are saved in. Defaults to out.")
+
+	flag.StringVar(
+		&params.OutputName,
+		"outName",
+		"{w}x{h}x{turn}",
+		"Specify the name of saved images, where {w}, {h}, {turn} and {input} stand for the world's size, the turn and the input file's name. Defaults to {w}x{h}x{turn}.")
+
+	flag.StringVar(
+		&params.Server,
+		"server",
+		"",
+		"Specify the host:port of the GoL server. Defaults to $GOL_SERVER, or 127.0.0.1:8030.")
+
+	flag.StringVar(
+		&params.Engine,
+		"engine",
+		"",
+		"Specify the engine to use: local or remote. Defaults to remote if a server is given, local otherwise.")
+
+	flag.StringVar(
+		&params.Session,
+		"session",
+		"",
+		"Specify a session on the server to reattach to. Defaults to any session with the same parameters.")
+
+	flag.Float64Var(
+		&params.TurnsPerSecond,
+		"tps",
+		0,
+		"Specify the most turns a second to run at; [ and ] change it while running. Defaults to no limit.")
+
+	flag.IntVar(
+		&params.Until,
+		"until",
+		0,
+		"Specify a turn to pause at, from which n steps one turn at a time. While running, type a turn then u to change it. Defaults to running on.")
+
 	noVis := flag.Bool(
 		"noVis",
 		false,
 		"Disables the SDL window, so there is no visualisation during the tests.")
 
+	terminal := flag.Bool(
+		"tui",
+		false,
+		"Draws the world in the terminal instead of the SDL window, for machines without a display.")
+
+	stampFile := flag.String(
+		"stamp",
+		"",
+		"Specify a pattern file to add to the stamps that can be placed in the SDL window. Defaults to only the built-in stamps.")
+
+	colourName := flag.String(
+		"colour",
+		"state",
+		"Specify how the SDL window, saved frames, PNG images and recordings colour cells: state, age since birth or death, or heat for how often each has changed. Defaults to state.")
+
+	var frames recorder.Options
+
+	flag.StringVar(
+		&frames.Path,
+		"frames",
+		"",
+		"Specify a directory to save numbered frames in, or a .y4m file to save a video to, in place of the SDL window. Defaults to no frames.")
+
+	flag.IntVar(
+		&frames.Every,
+		"frameEvery",
+		1,
+		"Specify N to save a frame every Nth turn. Defaults to 1.")
+
+	flag.StringVar(
+		&frames.Format,
+		"frameFormat",
+		"png",
+		"Specify the format of numbered frames: png or pgm. Defaults to png.")
+
+	flag.IntVar(
+		&frames.FPS,
+		"fps",
+		25,
+		"Specify the frame rate of a .y4m video. Defaults to 25.")
+
 	flag.Parse()
 
+	if params.Input != "" {
+		// Take the size of the world from the pattern file unless it was given.
+		sized := map[string]bool{}
+		flag.Visit(func(f *flag.Flag) { sized[f.Name] = true })
+		if !sized["w"] {
+			params.ImageWidth = 0
+		}
+		if !sized["h"] {
+			params.ImageHeight = 0
+		}
+	}
+	input, err := gol.ReadInput(params)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	params = input.Fit(params)
+
 	fmt.Println("Threads:", params.Threads)
 	fmt.Println("Width:", params.ImageWidth)
 	fmt.Println("Height:", params.ImageHeight)
 
+	colour, err := colouring.ParseMode(*colourName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	params.Colour = colour.String()
+
+	stamps := gol.BuiltinStamps()
+	if *stampFile != "" {
+		stamp, err := gol.LoadStamp(*stampFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		stamps = append(stamps, stamp)
+	}
+
 	keyPresses := make(chan rune, 10)
 	events := make(chan gol.Event, 1000)
+	edits := make(chan gol.Edit, 100)
 
-	go gol.Run(params, events, keyPresses)
-	if !(*noVis) {
-		sdl.Run(params, events, keyPresses)
+	go gol.RunInput(params, input, events, keyPresses, edits)
+	if frames.Path != "" {
+		frames.Scale = params.Scale
+		frames.Colour = colour
+		if err := recorder.Run(params, events, frames); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	} else if *terminal {
+		if err := tui.Run(params, events, keyPresses); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	} else if !(*noVis) {
+		sdl.Run(params, events, keyPresses, edits, stamps, colour)
 	} else {
 		complete := false
 		for !complete {
 			event := <-events
 			switch event.(type) {
+			case gol.Notice:
+				fmt.Println(event)
 			case gol.FinalTurnComplete:
 				complete = true
 			}
@@ -0,0 +1,158 @@
+// Package recorder is a headless visualiser: it rebuilds each frame from the events of a
+// run, as the SDL window does, and saves them for reviewing the run later.
+package recorder
+
+import (
+	"fmt"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/colouring"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Options says which frames are saved and where.
+type Options struct {
+	// Path is a .y4m file to write a video to, or else a directory for numbered images.
+	Path string
+	// Every saves every Every-th turn; 0 is taken as 1.
+	Every int
+	// Format is "png" or "pgm", the format of numbered images. If empty, PNG is used.
+	Format string
+	// Scale is the size in pixels of each cell; 0 is taken as 1.
+	Scale int
+	// FPS is the frame rate given in a video's header. If 0, 25 is used.
+	FPS int
+	// Colour is how cells are coloured. Frames are grey in State mode, and in colour
+	// otherwise, which PGM images cannot hold.
+	Colour colouring.Mode
+}
+
+// sink is where frames are saved.
+type sink interface {
+	// frame saves a frame of grey levels, one byte per pixel, or when colouring of RGBA
+	// colours, four bytes per pixel, row by row.
+	frame(pixels []byte) error
+	close() error
+}
+
+// draw returns the board coloured in mode, with each cell a scale by scale square: grey
+// levels in State mode and RGBA colours otherwise.
+func draw(b *colouring.Board, mode colouring.Mode, scale int) []byte {
+	depth := 4
+	if mode == colouring.State {
+		depth = 1
+	}
+	width := b.Width * scale * depth
+	pixels := make([]byte, width*b.Height*scale)
+	for y := 0; y < b.Height; y++ {
+		row := pixels[y*scale*width : (y*scale+1)*width]
+		for x := 0; x < b.Width; x++ {
+			at := row[x*scale*depth:]
+			if depth == 1 {
+				grey := b.Grey(x, y)
+				for dx := 0; dx < scale; dx++ {
+					at[dx] = grey
+				}
+				continue
+			}
+			r, g, bl := b.RGB(x, y, mode)
+			for dx := 0; dx < scale; dx++ {
+				at[4*dx], at[4*dx+1], at[4*dx+2], at[4*dx+3] = r, g, bl, 0xFF
+			}
+		}
+		for dy := 1; dy < scale; dy++ {
+			copy(pixels[(y*scale+dy)*width:], row)
+		}
+	}
+	return pixels
+}
+
+// Run consumes events in place of sdl.Run, saving the world every opts.Every turns and at
+// the end of the run. Like the window, it returns at FinalTurnComplete or when events is
+// closed.
+func Run(p gol.Params, events <-chan gol.Event, opts Options) error {
+	if opts.Every < 1 {
+		opts.Every = 1
+	}
+	if opts.Scale < 1 {
+		opts.Scale = 1
+	}
+	width, height := p.ImageWidth*opts.Scale, p.ImageHeight*opts.Scale
+	var out sink
+	var err error
+	colour := opts.Colour != colouring.State
+	if strings.HasSuffix(strings.ToLower(opts.Path), ".y4m") {
+		out, err = newY4mSink(opts.Path, width, height, opts.FPS, colour)
+	} else {
+		out, err = newImageSink(opts.Path, opts.Format, width, height, colour)
+	}
+	if err != nil {
+		return err
+	}
+	b := colouring.NewBoard(p.ImageWidth, p.ImageHeight)
+
+	// turn is the turn the board shows, or -1 until an event says, and saved is the last
+	// turn saved, or -1. Numbers are given to frames in order rather than by turn, so a
+	// sequence has no gaps when turns are skipped.
+	turn, saved := -1, -1
+	save := func() error {
+		saved = turn
+		return out.frame(draw(b, opts.Colour, opts.Scale))
+	}
+	// reach moves the board on to turn next. The world the run started from is complete
+	// once a later turn begins, and is always saved.
+	reach := func(next int) error {
+		if turn < 0 {
+			turn = next
+			return nil
+		}
+		if next <= turn {
+			return nil
+		}
+		var err error
+		if saved < 0 {
+			err = save()
+		}
+		turn = next
+		b.Complete(next)
+		return err
+	}
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			err = reach(e.CompletedTurns)
+			b.Flip(e.Cell, e.CompletedTurns)
+		case gol.CellStateChanged:
+			err = reach(e.CompletedTurns)
+			b.Set(e.Cell, util.StateGrey(e.State, e.States), e.CompletedTurns)
+		case gol.TurnComplete:
+			if turn < 0 {
+				// No cells were reported, so the run started from an empty world.
+				turn = e.CompletedTurns - 1
+			}
+			err = reach(e.CompletedTurns)
+			if err == nil && (saved < 0 || turn/opts.Every > saved/opts.Every) {
+				err = save()
+			}
+		case gol.FinalTurnComplete:
+			if err = reach(e.CompletedTurns); err == nil && turn != saved {
+				err = save()
+			}
+			if err != nil {
+				out.close()
+				return err
+			}
+			return out.close()
+		default:
+			if len(event.String()) > 0 {
+				fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
+			}
+		}
+		if err != nil {
+			out.close()
+			return err
+		}
+	}
+	return out.close()
+}
@@ -0,0 +1,127 @@
+package recorder
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// imageSink saves each frame as a numbered image in a directory: frame-000000.png,
+// frame-000001.png and so on.
+type imageSink struct {
+	dir           string
+	format        string
+	width, height int
+	colour        bool
+	frames        int
+}
+
+func newImageSink(dir, format string, width, height int, colour bool) (*imageSink, error) {
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "pgm" {
+		return nil, fmt.Errorf("invalid frame format %q: want png or pgm", format)
+	}
+	if format == "pgm" && colour {
+		return nil, fmt.Errorf("pgm frames are grey: use png to save them in colour")
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &imageSink{dir: dir, format: format, width: width, height: height, colour: colour}, nil
+}
+
+func (s *imageSink) frame(pixels []byte) error {
+	file, err := os.Create(filepath.Join(s.dir, fmt.Sprintf("frame-%06d.%s", s.frames, s.format)))
+	if err != nil {
+		return err
+	}
+	s.frames++
+	if s.format == "pgm" {
+		out := bufio.NewWriter(file)
+		fmt.Fprintf(out, "P5\n%d %d\n255\n", s.width, s.height)
+		out.Write(pixels)
+		err = out.Flush()
+	} else if s.colour {
+		err = png.Encode(file, &image.RGBA{Pix: pixels, Stride: 4 * s.width, Rect: image.Rect(0, 0, s.width, s.height)})
+	} else {
+		err = png.Encode(file, &image.Gray{Pix: pixels, Stride: s.width, Rect: image.Rect(0, 0, s.width, s.height)})
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (s *imageSink) close() error {
+	fmt.Println("Saved", s.frames, "frames to", s.dir)
+	return nil
+}
+
+// y4mSink saves the frames as an uncompressed YUV4MPEG2 video, which tools such as ffmpeg
+// can read. Grey frames are saved in monochrome, and colour ones with full resolution
+// chroma.
+type y4mSink struct {
+	file   *os.File
+	out    *bufio.Writer
+	colour bool
+	frames int
+	// planes holds the Y, Cb and Cr planes of a colour frame.
+	planes []byte
+}
+
+func newY4mSink(path string, width, height, fps int, colour bool) (*y4mSink, error) {
+	if fps < 1 {
+		fps = 25
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	out := bufio.NewWriter(file)
+	if !colour {
+		fmt.Fprintf(out, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 Cmono\n", width, height, fps)
+		return &y4mSink{file: file, out: out}, nil
+	}
+	fmt.Fprintf(out, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C444\n", width, height, fps)
+	return &y4mSink{file: file, out: out, colour: true, planes: make([]byte, 3*width*height)}, nil
+}
+
+func (s *y4mSink) frame(pixels []byte) error {
+	s.frames++
+	s.out.WriteString("FRAME\n")
+	if s.colour {
+		// Convert from RGBA to the limited range BT.601 YCbCr that players assume.
+		n := len(s.planes) / 3
+		for i := 0; i < n; i++ {
+			r, g, b := float64(pixels[4*i]), float64(pixels[4*i+1]), float64(pixels[4*i+2])
+			s.planes[i] = uint8(16.5 + (65.481*r+128.553*g+24.966*b)/255)
+			s.planes[n+i] = uint8(128.5 + (-37.797*r-74.203*g+112*b)/255)
+			s.planes[2*n+i] = uint8(128.5 + (112*r-93.786*g-18.214*b)/255)
+		}
+		pixels = s.planes
+	}
+	_, err := s.out.Write(pixels)
+	return err
+}
+
+func (s *y4mSink) close() error {
+	err := s.out.Flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		fmt.Println("Saved", s.frames, "frames to", s.file.Name())
+	}
+	return err
+}
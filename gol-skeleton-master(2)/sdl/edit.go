@@ -0,0 +1,159 @@
+package sdl
+
+import (
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// editor turns the mouse into edits of the world. While the simulation is paused, dragging
+// with the left button draws cells, starting from the opposite of the first cell's state,
+// and dragging with the right button erases them. The keys 1 to 9 pick a stamp, which a
+// left click places centred on the cursor whether paused or not; r turns it and Escape puts
+// it away.
+type editor struct {
+	w      *Window
+	edits  chan<- gol.Edit
+	stamps []gol.Stamp
+	// stamp is the stamp being placed, or nil when drawing.
+	stamp  *gol.Stamp
+	paused bool
+	// drawing is set while a button is held, making the cells dragged over alive, or dead
+	// if alive is false. last is the cell the drag last reached.
+	drawing bool
+	alive   bool
+	last    util.Cell
+	// cursor is the cell under the mouse.
+	cursor util.Cell
+}
+
+// key handles the keys that pick, turn and put away stamps, reporting whether it was one.
+func (e *editor) key(sym sdl.Keycode) bool {
+	switch {
+	case sym >= sdl.K_1 && sym <= sdl.K_9:
+		i := int(sym - sdl.K_1)
+		if i >= len(e.stamps) {
+			return true
+		}
+		stamp := e.stamps[i]
+		e.stamp = &stamp
+		fmt.Println("Stamping", stamp.Name)
+	case sym == sdl.K_r && e.stamp != nil:
+		rotated := e.stamp.Rotate()
+		e.stamp = &rotated
+	case sym == sdl.K_ESCAPE && e.stamp != nil:
+		e.stamp = nil
+	default:
+		return false
+	}
+	e.preview()
+	return true
+}
+
+// wouldEdit reports whether pressing button would edit the world rather than pan it.
+func (e *editor) wouldEdit(button uint8) bool {
+	if button == sdl.BUTTON_LEFT && e.stamp != nil {
+		return true
+	}
+	return e.paused && (button == sdl.BUTTON_LEFT || button == sdl.BUTTON_RIGHT)
+}
+
+func (e *editor) mouseButton(event *sdl.MouseButtonEvent) {
+	cell := e.w.CellAt(event.X, event.Y)
+	e.cursor = cell
+	if event.Type == sdl.MOUSEBUTTONUP {
+		e.drawing = false
+		return
+	}
+	if e.stamp != nil && event.Button == sdl.BUTTON_LEFT {
+		e.send(e.stamp.At(cell.X, cell.Y), true)
+		return
+	}
+	if !e.paused || !e.w.Contains(cell.X, cell.Y) {
+		return
+	}
+	switch event.Button {
+	case sdl.BUTTON_LEFT:
+		e.drawing, e.alive, e.last = true, !e.w.CellLit(cell.X, cell.Y), cell
+	case sdl.BUTTON_RIGHT:
+		e.drawing, e.alive, e.last = true, false, cell
+	default:
+		return
+	}
+	e.send([]util.Cell{cell}, e.alive)
+}
+
+func (e *editor) mouseMotion(event *sdl.MouseMotionEvent) {
+	cell := e.w.CellAt(event.X, event.Y)
+	e.cursor = cell
+	if e.drawing && e.paused && cell != e.last {
+		e.send(line(e.last, cell), e.alive)
+		e.last = cell
+	}
+	if e.stamp != nil {
+		e.preview()
+	}
+}
+
+// preview shows the stamp at the cursor, or nothing if there is no stamp.
+func (e *editor) preview() {
+	if e.stamp == nil {
+		e.w.SetOverlay(nil)
+	} else {
+		e.w.SetOverlay(e.stamp.At(e.cursor.X, e.cursor.Y))
+	}
+	e.w.RenderFrame()
+}
+
+// send asks for the cells within the window to be made alive or dead.
+func (e *editor) send(cells []util.Cell, alive bool) {
+	if e.edits == nil {
+		return
+	}
+	inside := cells[:0:0]
+	for _, cell := range cells {
+		if e.w.Contains(cell.X, cell.Y) {
+			inside = append(inside, cell)
+		}
+	}
+	if len(inside) > 0 {
+		e.edits <- gol.Edit{Cells: inside, Alive: alive}
+	}
+}
+
+// line returns the cells on the straight line from a to b, leaving out a, so that a quick
+// drag does not leave gaps.
+func line(a, b util.Cell) []util.Cell {
+	dx, dy := abs(b.X-a.X), -abs(b.Y-a.Y)
+	sx, sy := 1, 1
+	if b.X < a.X {
+		sx = -1
+	}
+	if b.Y < a.Y {
+		sy = -1
+	}
+	var cells []util.Cell
+	x, y, err := a.X, a.Y, dx+dy
+	for x != b.X || y != b.Y {
+		twice := 2 * err
+		if twice >= dy {
+			err += dy
+			x += sx
+		}
+		if twice <= dx {
+			err += dx
+			y += sy
+		}
+		cells = append(cells, util.Cell{X: x, Y: y})
+	}
+	return cells
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+package sdl
+
+import (
+	"fmt"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// rateInterval is the least time over which turns are counted to give the rate, so that it
+// does not jump about from turn to turn.
+const rateInterval = 500 * time.Millisecond
+
+// hud keeps the figures shown over the world: the turns completed, the latest count of
+// alive cells, the turns a second and the state of execution, with any speed limit, turn
+// to run until and latest notice.
+type hud struct {
+	shown bool
+	turn  int
+	// alive is the latest AliveCellsCount, and counted is false until one has come.
+	alive   int
+	counted bool
+	state   gol.State
+	// limit and until are the speed limit and the turn to run until, or 0 if none.
+	limit float64
+	until int
+	rate  float64
+	// notice is the message of the latest Notice, or empty if none has come.
+	notice string
+	// since is when the rate was last worked out, at sinceTurn.
+	since     time.Time
+	sinceTurn int
+}
+
+func newHUD() *hud {
+	return &hud{shown: true, state: gol.Executing, since: time.Now()}
+}
+
+// event takes in the figures from an event, reporting whether any that are shown changed.
+func (h *hud) event(event gol.Event) bool {
+	switch e := event.(type) {
+	case gol.TurnComplete:
+		h.turn = e.CompletedTurns
+		if elapsed := time.Since(h.since); elapsed >= rateInterval {
+			h.rate = float64(h.turn-h.sinceTurn) / elapsed.Seconds()
+			h.since, h.sinceTurn = time.Now(), h.turn
+		}
+	case gol.AliveCellsCount:
+		h.alive, h.counted = e.CellsCount, true
+	case gol.Notice:
+		h.notice = e.Message
+	case gol.StateChange:
+		h.turn = e.CompletedTurns
+		h.state = e.NewState
+		h.limit, h.until = e.TurnsPerSecond, e.Until
+		// The rate is counted afresh once running again, so the pause does not slow it.
+		h.since, h.sinceTurn = time.Now(), h.turn
+		if h.state != gol.Executing {
+			h.rate = 0
+		}
+	default:
+		return false
+	}
+	return h.shown
+}
+
+// lines returns the text to draw over the world, or nil if it is hidden.
+func (h *hud) lines() []string {
+	if !h.shown {
+		return nil
+	}
+	alive := "-"
+	if h.counted {
+		alive = fmt.Sprint(h.alive)
+	}
+	lines := []string{
+		fmt.Sprintf("Turn  %d", h.turn),
+		fmt.Sprintf("Alive %s", alive),
+		fmt.Sprintf("Rate  %.1f turns/s", h.rate),
+		fmt.Sprintf("State %v", h.state),
+	}
+	if h.limit > 0 {
+		lines = append(lines, fmt.Sprintf("Limit %g turns/s", h.limit))
+	}
+	if h.until > 0 {
+		lines = append(lines, fmt.Sprintf("Until %d", h.until))
+	}
+	if h.notice != "" {
+		lines = append(lines, h.notice)
+	}
+	return lines
+}
@@ -2,12 +2,39 @@ package sdl
 
 import (
 	"fmt"
+	"math"
+
 	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/colouring"
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
-func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
+// wheelZoom is how much each notch of the mouse wheel zooms by.
+const wheelZoom = 1.25
+
+// Run shows the events in a window until FinalTurnComplete, sending the keys pressed in it
+// to keyPresses. The mouse draws on the world and places stamps as editor describes, with
+// each change sent to edits; if edits is nil the world cannot be edited.
+//
+// The mouse wheel zooms about the cursor, and dragging with the middle button, or with the
+// left one when it would not edit, pans. The keys + and - zoom to whole numbers of pixels a
+// cell, f fits the world to the window, 0 shows it at 1:1, the arrow keys pan and g turns
+// the grid on and off.
+//
+// The turns completed, the latest count of alive cells, the turns a second and the state of
+// execution are shown over the top-left of the world; h hides and shows them. As well as p,
+// s, q and k, the keys n, [ and ] are sent on to step a paused run and to slow it down and
+// speed it up.
+//
+// Cells are first coloured as colour says, and c moves on to the next way of colouring.
+func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune, edits chan<- gol.Edit, stamps []gol.Stamp, colour colouring.Mode) {
 	w := NewWindow(int32(p.ImageWidth), int32(p.ImageHeight))
+	w.SetColouring(colour)
+	edit := &editor{w: w, edits: edits, stamps: stamps}
+	panning := false
+	stats := newHUD()
+	w.SetText(stats.lines())
 
 sdlLoop:
 	for {
@@ -24,6 +51,57 @@ sdlLoop:
 					keyPresses <- 'q'
 				case sdl.K_k:
 					keyPresses <- 'k'
+				case sdl.K_n:
+					keyPresses <- 'n'
+				case sdl.K_LEFTBRACKET:
+					keyPresses <- '['
+				case sdl.K_RIGHTBRACKET:
+					keyPresses <- ']'
+				case sdl.K_u:
+					keyPresses <- 'u'
+				case sdl.K_KP_0, sdl.K_KP_1, sdl.K_KP_2, sdl.K_KP_3, sdl.K_KP_4,
+					sdl.K_KP_5, sdl.K_KP_6, sdl.K_KP_7, sdl.K_KP_8, sdl.K_KP_9:
+					// The number keys pick stamps, so the turn to run until is typed
+					// on the keypad.
+					keyPresses <- keypadDigit(e.Keysym.Sym)
+				case sdl.K_c:
+					w.SetColouring(w.Colouring().Next())
+					fmt.Println("Colouring by", w.Colouring())
+					w.RenderFrame()
+				case sdl.K_h:
+					stats.shown = !stats.shown
+					w.SetText(stats.lines())
+					w.RenderFrame()
+				default:
+					if !edit.key(e.Keysym.Sym) && viewKey(w, e.Keysym.Sym) {
+						w.RenderFrame()
+					}
+				}
+			case *sdl.MouseButtonEvent:
+				if e.Type == sdl.MOUSEBUTTONDOWN {
+					panning = e.Button == sdl.BUTTON_MIDDLE || e.Button == sdl.BUTTON_LEFT && !edit.wouldEdit(e.Button)
+				} else {
+					panning = false
+				}
+				edit.mouseButton(e)
+			case *sdl.MouseMotionEvent:
+				if panning {
+					w.Pan(e.XRel, e.YRel)
+					w.RenderFrame()
+				}
+				edit.mouseMotion(e)
+			case *sdl.MouseWheelEvent:
+				notches := float64(e.Y)
+				if e.Direction == sdl.MOUSEWHEEL_FLIPPED {
+					notches = -notches
+				}
+				x, y, _ := sdl.GetMouseState()
+				w.ZoomAt(math.Pow(wheelZoom, notches), x, y)
+				w.RenderFrame()
+			case *sdl.WindowEvent:
+				if e.Event == sdl.WINDOWEVENT_SIZE_CHANGED {
+					w.Resize(e.Data1, e.Data2)
+					w.RenderFrame()
 				}
 			}
 		}
@@ -35,12 +113,28 @@ sdlLoop:
 			}
 			switch e := event.(type) {
 			case gol.CellFlipped:
-				w.FlipPixel(e.Cell.X, e.Cell.Y)
+				w.FlipCell(e.Cell.X, e.Cell.Y, e.CompletedTurns)
+			case gol.CellStateChanged:
+				w.SetCellGrey(e.Cell.X, e.Cell.Y, util.StateGrey(e.State, e.States), e.CompletedTurns)
 			case gol.TurnComplete:
+				w.CompleteTurn(e.CompletedTurns)
+				stats.event(e)
+				w.SetText(stats.lines())
 				w.RenderFrame()
 			case gol.FinalTurnComplete:
 				w.Destroy()
 				break sdlLoop
+			case gol.StateChange:
+				edit.paused = e.NewState == gol.Paused
+				if stats.event(e) {
+					w.SetText(stats.lines())
+					w.RenderFrame()
+				}
+			case gol.AliveCellsCount, gol.Notice:
+				if stats.event(e) {
+					w.SetText(stats.lines())
+					w.RenderFrame()
+				}
 			default:
 				if len(event.String()) > 0 {
 					fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
@@ -52,3 +146,39 @@ sdlLoop:
 	}
 
 }
+
+// keypadDigit returns the digit on a keypad key. SDL numbers the keys 1 to 9 in order,
+// followed by 0.
+func keypadDigit(sym sdl.Keycode) rune {
+	if sym == sdl.K_KP_0 {
+		return '0'
+	}
+	return '1' + rune(sym-sdl.K_KP_1)
+}
+
+// viewKey handles the keys that zoom and pan the window, reporting whether it was one.
+func viewKey(w *Window, sym sdl.Keycode) bool {
+	switch sym {
+	case sdl.K_EQUALS, sdl.K_PLUS, sdl.K_KP_PLUS:
+		w.StepZoom(1)
+	case sdl.K_MINUS, sdl.K_KP_MINUS:
+		w.StepZoom(-1)
+	case sdl.K_f:
+		w.Fit()
+	case sdl.K_0:
+		w.ActualSize()
+	case sdl.K_g:
+		w.ToggleGrid()
+	case sdl.K_UP:
+		w.PanPage(0, -1)
+	case sdl.K_DOWN:
+		w.PanPage(0, 1)
+	case sdl.K_LEFT:
+		w.PanPage(-1, 0)
+	case sdl.K_RIGHT:
+		w.PanPage(1, 0)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package sdl
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+const (
+	// Each glyph is glyphWidth by glyphHeight pixels, and text is drawn advance pixels a
+	// character and lineHeight pixels a line, with textPadding pixels around it.
+	glyphWidth  = 5
+	glyphHeight = 7
+	advance     = glyphWidth + 1
+	lineHeight  = glyphHeight + 2
+	textPadding = 3
+	// textScale is the number of window pixels across each pixel of a glyph.
+	textScale = 2
+	// textMargin is the gap in window pixels between the text and the window's edges.
+	textMargin = 6
+	// maxColumns and maxLines bound the text, longer lines and later ones being cut off.
+	maxColumns = 40
+	maxLines   = 8
+)
+
+// font is a 5x7 bitmap font with one byte a row, the leftmost pixel in bit 4. It has only
+// capitals, so lower case letters are drawn as those, and any other character missing from
+// it is drawn as a question mark.
+var font = map[rune][glyphHeight]byte{
+	' ':  {},
+	'0':  {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1':  {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2':  {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3':  {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4':  {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5':  {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6':  {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7':  {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8':  {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9':  {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'A':  {0x0E, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'B':  {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C':  {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D':  {0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C},
+	'E':  {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F':  {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G':  {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H':  {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I':  {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J':  {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K':  {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L':  {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M':  {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N':  {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O':  {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P':  {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q':  {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R':  {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S':  {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T':  {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W':  {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X':  {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y':  {0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04},
+	'Z':  {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	'.':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	',':  {0x00, 0x00, 0x00, 0x00, 0x0C, 0x04, 0x08},
+	':':  {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	'/':  {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'-':  {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'+':  {0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00},
+	'=':  {0x00, 0x00, 0x1F, 0x00, 0x1F, 0x00, 0x00},
+	'_':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F},
+	'%':  {0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03},
+	'(':  {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02},
+	')':  {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},
+	'\'': {0x0C, 0x04, 0x08, 0x00, 0x00, 0x00, 0x00},
+	'!':  {0x04, 0x04, 0x04, 0x04, 0x04, 0x00, 0x04},
+	'?':  {0x0E, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},
+}
+
+// glyph returns the bitmap drawn for r.
+func glyph(r rune) [glyphHeight]byte {
+	if g, ok := font[unicode.ToUpper(r)]; ok {
+		return g
+	}
+	return font['?']
+}
+
+// text is the lines drawn over the top-left of the window, rendered into a texture of its
+// own.
+type text struct {
+	lines   []string
+	texture *sdl.Texture
+	pixels  []byte
+	// rendered is the lines as last put in the texture, joined, and size is the part of the
+	// texture they took up.
+	rendered string
+	size     sdl.Rect
+}
+
+// textSize returns the width and height of the texture that holds the most text.
+func textSize() (int32, int32) {
+	return 2*textPadding + maxColumns*advance - 1, 2*textPadding + maxLines*lineHeight - 2
+}
+
+func newText(renderer *sdl.Renderer) *text {
+	width, height := textSize()
+	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STATIC, width, height)
+	util.Check(err)
+	// The background is drawn see-through so the cells beneath it still show.
+	err = texture.SetBlendMode(sdl.BLENDMODE_BLEND)
+	util.Check(err)
+	return &text{texture: texture, pixels: make([]byte, width*height*4)}
+}
+
+// render draws the lines into the texture, if they have changed since it last did.
+func (t *text) render() {
+	joined := strings.Join(t.lines, "\n")
+	if joined == t.rendered {
+		return
+	}
+	t.rendered = joined
+	lines := t.lines
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+	}
+	columns := 0
+	for _, line := range lines {
+		if n := len([]rune(line)); n > columns {
+			columns = n
+		}
+	}
+	if columns > maxColumns {
+		columns = maxColumns
+	}
+	pitch, _ := textSize()
+	width, height := 2*textPadding+columns*advance-1, 2*textPadding+len(lines)*lineHeight-2
+	t.size = sdl.Rect{W: int32(width), H: int32(height)}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// Pixels are stored as ARGB8888 words, so in memory the bytes run B, G, R, A.
+			i := 4 * (y*int(pitch) + x)
+			t.pixels[i+0], t.pixels[i+1], t.pixels[i+2], t.pixels[i+3] = 0x00, 0x00, 0x00, 0xB0
+		}
+	}
+	for row, line := range lines {
+		for column, r := range []rune(line) {
+			if column == maxColumns {
+				break
+			}
+			g := glyph(r)
+			for gy := 0; gy < glyphHeight; gy++ {
+				for gx := 0; gx < glyphWidth; gx++ {
+					if g[gy]&(0x10>>uint(gx)) == 0 {
+						continue
+					}
+					x, y := textPadding+column*advance+gx, textPadding+row*lineHeight+gy
+					i := 4 * (y*int(pitch) + x)
+					t.pixels[i+0], t.pixels[i+1], t.pixels[i+2], t.pixels[i+3] = 0xFF, 0xFF, 0xFF, 0xFF
+				}
+			}
+		}
+	}
+	err := t.texture.Update(&t.size, t.pixels, int(pitch)*4)
+	util.Check(err)
+}
+
+// draw copies the text to the top-left of the window.
+func (t *text) draw(renderer *sdl.Renderer) {
+	if len(t.lines) == 0 {
+		return
+	}
+	t.render()
+	screen := sdl.Rect{X: textMargin, Y: textMargin, W: t.size.W * textScale, H: t.size.H * textScale}
+	err := renderer.Copy(t.texture, &t.size, &screen)
+	util.Check(err)
+}
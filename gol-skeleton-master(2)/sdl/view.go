@@ -0,0 +1,214 @@
+package sdl
+
+import (
+	"math"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+const (
+	// minWindow and maxWindow bound the width and height the window opens at, so a small
+	// world is drawn with large cells and a large one is drawn shrunk to fit the screen.
+	minWindow = 512
+	maxWindow = 1024
+	// maxZoom is the most pixels across a cell may be drawn.
+	maxZoom = 64
+	// gridZoom is the least zoom at which the grid is drawn, as below it the lines would
+	// hide the cells.
+	gridZoom = 4
+)
+
+// view is the part of the world the window shows.
+type view struct {
+	// zoom is the number of pixels across each cell, which need not be whole.
+	zoom float64
+	// x and y are the position in the world, in cells, at the window's top-left corner.
+	x, y float64
+	// screenWidth and screenHeight are the size of the window.
+	screenWidth, screenHeight int32
+	grid                      bool
+}
+
+// openingZoom returns the zoom the window opens at for a world of the given size.
+func openingZoom(width, height int32) float64 {
+	side := width
+	if height > side {
+		side = height
+	}
+	switch {
+	case side < minWindow:
+		return float64(minWindow / side)
+	case side > maxWindow:
+		return float64(maxWindow) / float64(side)
+	}
+	return 1
+}
+
+// fitZoom returns the zoom at which the whole world just fits in the window.
+func (w *Window) fitZoom() float64 {
+	return math.Min(float64(w.view.screenWidth)/float64(w.Width), float64(w.view.screenHeight)/float64(w.Height))
+}
+
+// setZoom changes the zoom, keeping the point (sx, sy) of the window over the same part of
+// the world. The world cannot be shrunk below 1:1 unless that is needed to fit it in the
+// window.
+func (w *Window) setZoom(zoom float64, sx, sy int32) {
+	zoom = math.Min(zoom, maxZoom)
+	zoom = math.Max(zoom, math.Min(1, w.fitZoom()))
+	v := &w.view
+	x, y := v.x+float64(sx)/v.zoom, v.y+float64(sy)/v.zoom
+	v.zoom = zoom
+	v.x, v.y = x-float64(sx)/zoom, y-float64(sy)/zoom
+	w.clampView()
+}
+
+// clampView keeps the world on screen, centring it along any side that it does not fill.
+func (w *Window) clampView() {
+	v := &w.view
+	v.x = clampAxis(v.x, float64(w.Width), float64(v.screenWidth)/v.zoom)
+	v.y = clampAxis(v.y, float64(w.Height), float64(v.screenHeight)/v.zoom)
+}
+
+// clampAxis returns the position of a view that is shown cells long on a world that is
+// size cells long, moved as little as possible so that it stays within the world.
+func clampAxis(at, size, shown float64) float64 {
+	if shown >= size {
+		return (size - shown) / 2
+	}
+	return math.Max(0, math.Min(at, size-shown))
+}
+
+// ZoomAt zooms in by factor, or out if it is less than one, about the point (sx, sy) of the
+// window.
+func (w *Window) ZoomAt(factor float64, sx, sy int32) {
+	w.setZoom(w.view.zoom*factor, sx, sy)
+}
+
+// StepZoom zooms in to the next whole number of pixels a cell, or when steps is negative
+// out to the previous one, about the centre of the window. Below 1:1 the steps are whole
+// numbers of cells a pixel.
+func (w *Window) StepZoom(steps int) {
+	// Levels 1, 2, 3 and on are that many pixels a cell; levels 0, -1, -2 and on are 2, 3,
+	// 4 and on cells a pixel.
+	level := w.view.zoom
+	if level < 1 {
+		level = 2 - 1/level
+	}
+	if steps > 0 {
+		level = math.Floor(level+0.001) + float64(steps)
+	} else {
+		level = math.Ceil(level-0.001) + float64(steps)
+	}
+	zoom := level
+	if level < 1 {
+		zoom = 1 / (2 - level)
+	}
+	w.setZoom(zoom, w.view.screenWidth/2, w.view.screenHeight/2)
+}
+
+// Fit zooms so that the whole world fills the window.
+func (w *Window) Fit() {
+	w.view.zoom = math.Min(w.fitZoom(), maxZoom)
+	w.clampView()
+}
+
+// ActualSize zooms to one pixel a cell, about the centre of the window.
+func (w *Window) ActualSize() {
+	w.setZoom(1, w.view.screenWidth/2, w.view.screenHeight/2)
+}
+
+// Pan moves the world by dx and dy pixels, as when dragging it.
+func (w *Window) Pan(dx, dy int32) {
+	w.view.x -= float64(dx) / w.view.zoom
+	w.view.y -= float64(dy) / w.view.zoom
+	w.clampView()
+}
+
+// PanPage moves the view by a quarter of the window in each direction given.
+func (w *Window) PanPage(dx, dy int) {
+	w.Pan(int32(-dx)*w.view.screenWidth/4, int32(-dy)*w.view.screenHeight/4)
+}
+
+// ToggleGrid turns on or off the lines drawn between cells when zoomed in.
+func (w *Window) ToggleGrid() {
+	w.view.grid = !w.view.grid
+}
+
+// Resize takes the window to be the given size, after the user has resized it.
+func (w *Window) Resize(width, height int32) {
+	if width < 1 || height < 1 {
+		return
+	}
+	w.view.screenWidth, w.view.screenHeight = width, height
+	err := w.renderer.SetLogicalSize(width, height)
+	util.Check(err)
+	w.setZoom(w.view.zoom, 0, 0)
+}
+
+// CellAt returns the cell under the point (sx, sy) of the window, which may lie outside the
+// world.
+func (w *Window) CellAt(sx, sy int32) util.Cell {
+	return util.Cell{
+		X: int(math.Floor(w.view.x + float64(sx)/w.view.zoom)),
+		Y: int(math.Floor(w.view.y + float64(sy)/w.view.zoom)),
+	}
+}
+
+// screenX and screenY return the position in the window of the edge of a cell.
+func (w *Window) screenX(x int) int32 {
+	return int32(math.Round((float64(x) - w.view.x) * w.view.zoom))
+}
+
+func (w *Window) screenY(y int) int32 {
+	return int32(math.Round((float64(y) - w.view.y) * w.view.zoom))
+}
+
+// visible returns the cells that are at least partly in the window, and where in the
+// window they are drawn.
+func (w *Window) visible() (cells, screen sdl.Rect) {
+	v := &w.view
+	x0 := int(math.Max(0, math.Floor(v.x)))
+	y0 := int(math.Max(0, math.Floor(v.y)))
+	x1 := int(math.Min(float64(w.Width), math.Ceil(v.x+float64(v.screenWidth)/v.zoom)))
+	y1 := int(math.Min(float64(w.Height), math.Ceil(v.y+float64(v.screenHeight)/v.zoom)))
+	if x1 <= x0 || y1 <= y0 {
+		return sdl.Rect{}, sdl.Rect{}
+	}
+	cells = sdl.Rect{X: int32(x0), Y: int32(y0), W: int32(x1 - x0), H: int32(y1 - y0)}
+	screen = sdl.Rect{X: w.screenX(x0), Y: w.screenY(y0)}
+	screen.W, screen.H = w.screenX(x1)-screen.X, w.screenY(y1)-screen.Y
+	return cells, screen
+}
+
+// cellRect returns where in the window a cell is drawn, at least one pixel across.
+func (w *Window) cellRect(cell util.Cell) sdl.Rect {
+	r := sdl.Rect{X: w.screenX(cell.X), Y: w.screenY(cell.Y)}
+	r.W, r.H = w.screenX(cell.X+1)-r.X, w.screenY(cell.Y+1)-r.Y
+	if r.W < 1 {
+		r.W = 1
+	}
+	if r.H < 1 {
+		r.H = 1
+	}
+	return r
+}
+
+// drawGrid draws lines between the visible cells when they are large enough to see them.
+func (w *Window) drawGrid(cells, screen sdl.Rect) {
+	if !w.view.grid || w.view.zoom < gridZoom {
+		return
+	}
+	err := w.renderer.SetDrawColor(0x40, 0x40, 0x40, 0xFF)
+	util.Check(err)
+	for x := int(cells.X); x <= int(cells.X+cells.W); x++ {
+		sx := w.screenX(x)
+		err = w.renderer.DrawLine(sx, screen.Y, sx, screen.Y+screen.H)
+		util.Check(err)
+	}
+	for y := int(cells.Y); y <= int(cells.Y+cells.H); y++ {
+		sy := w.screenY(y)
+		err = w.renderer.DrawLine(screen.X, sy, screen.X+screen.W, sy)
+		util.Check(err)
+	}
+}
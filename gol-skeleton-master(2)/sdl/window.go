@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/colouring"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
@@ -13,38 +14,62 @@ type Window struct {
 	renderer      *sdl.Renderer
 	texture       *sdl.Texture
 	pixels        []byte
+	// overlay is drawn over the cells in the next frames, to preview a stamp.
+	overlay []util.Cell
+	view    view
+	// text is drawn over the top-left of the window.
+	text *text
+	// board is the history of the cells given to FlipCell and SetCellGrey, which they are
+	// coloured by unless colour is State.
+	board  *colouring.Board
+	colour colouring.Mode
 }
 
 func filterEvent(e sdl.Event, userdata interface{}) bool {
-	return e.GetType() == sdl.KEYDOWN || e.GetType() == sdl.QUIT
+	switch e.GetType() {
+	case sdl.KEYDOWN, sdl.QUIT, sdl.MOUSEBUTTONDOWN, sdl.MOUSEBUTTONUP, sdl.MOUSEMOTION, sdl.MOUSEWHEEL, sdl.WINDOWEVENT:
+		return true
+	}
+	return false
 }
 
+// NewWindow opens a window for a world of width by height cells. Small worlds are drawn
+// with each cell several pixels across and large ones shrunk to fit, and the window can be
+// resized, zoomed and panned.
 func NewWindow(width, height int32) *Window {
 	err := sdl.Init(sdl.INIT_EVERYTHING)
 	util.Check(err)
-	window, err := sdl.CreateWindow("GOL GUI", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, width, height, sdl.WINDOW_SHOWN)
+	zoom := openingZoom(width, height)
+	screenWidth, screenHeight := int32(float64(width)*zoom), int32(float64(height)*zoom)
+	window, err := sdl.CreateWindow("GOL GUI", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, screenWidth, screenHeight, sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
 	util.Check(err)
 	renderer, err := sdl.CreateRenderer(window, -1, sdl.WINDOW_SHOWN)
 	util.Check(err)
-	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "linear")
-	err = renderer.SetLogicalSize(width, height)
+	// Zoomed in cells are drawn as sharp squares rather than blurred together.
+	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "nearest")
+	err = renderer.SetLogicalSize(screenWidth, screenHeight)
 	util.Check(err)
 	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STATIC, width, height)
 	util.Check(err)
 
 	sdl.SetEventFilterFunc(filterEvent, nil)
 	return &Window{
-		width,
-		height,
-		window,
-		renderer,
-		texture,
-		make([]byte, width*height*4),
+		Width:    width,
+		Height:   height,
+		window:   window,
+		renderer: renderer,
+		texture:  texture,
+		pixels:   make([]byte, width*height*4),
+		view:     view{zoom: zoom, screenWidth: screenWidth, screenHeight: screenHeight},
+		text:     newText(renderer),
+		board:    colouring.NewBoard(int(width), int(height)),
 	}
 }
 
 func (w *Window) Destroy() {
-	err := w.texture.Destroy()
+	err := w.text.texture.Destroy()
+	util.Check(err)
+	err = w.texture.Destroy()
 	util.Check(err)
 	err = w.renderer.Destroy()
 	util.Check(err)
@@ -53,16 +78,115 @@ func (w *Window) Destroy() {
 	sdl.Quit()
 }
 
+// RenderFrame draws the part of the world in view, updating only that part of the texture.
 func (w *Window) RenderFrame() {
-	err := w.texture.Update(nil, w.pixels, int(w.Width*4))
+	cells, screen := w.visible()
+	if w.colour != colouring.State {
+		// Colours change with age as well as state, so every cell in view is coloured
+		// afresh.
+		for y := int(cells.Y); y < int(cells.Y+cells.H); y++ {
+			for x := int(cells.X); x < int(cells.X+cells.W); x++ {
+				w.paint(x, y)
+			}
+		}
+	}
+	err := w.renderer.SetDrawColor(0x00, 0x00, 0x00, 0xFF)
 	util.Check(err)
 	err = w.renderer.Clear()
 	util.Check(err)
-	err = w.renderer.Copy(w.texture, nil, nil)
-	util.Check(err)
+	if cells.W > 0 {
+		first := 4 * (int(cells.Y)*int(w.Width) + int(cells.X))
+		err = w.texture.Update(&cells, w.pixels[first:], int(w.Width*4))
+		util.Check(err)
+		err = w.renderer.Copy(w.texture, &cells, &screen)
+		util.Check(err)
+		w.drawGrid(cells, screen)
+	}
+	if len(w.overlay) > 0 {
+		err = w.renderer.SetDrawColor(0x00, 0xA0, 0xFF, 0xFF)
+		util.Check(err)
+		for _, cell := range w.overlay {
+			if w.Contains(cell.X, cell.Y) {
+				rect := w.cellRect(cell)
+				err = w.renderer.FillRect(&rect)
+				util.Check(err)
+			}
+		}
+	}
+	w.text.draw(w.renderer)
 	w.renderer.Present()
 }
 
+// SetOverlay sets the cells drawn over the world from the next frame on; nil clears them.
+func (w *Window) SetOverlay(cells []util.Cell) {
+	w.overlay = cells
+}
+
+// SetText sets the lines of text drawn over the top-left of the window from the next frame
+// on; nil clears them.
+func (w *Window) SetText(lines []string) {
+	w.text.lines = lines
+}
+
+// Contains reports whether (x, y) is a pixel of the window.
+func (w *Window) Contains(x, y int) bool {
+	return x >= 0 && y >= 0 && x < int(w.Width) && y < int(w.Height)
+}
+
+// CellLit reports whether the cell at (x, y) is not dead.
+func (w *Window) CellLit(x, y int) bool {
+	return w.board.Grey(x, y) != 0
+}
+
+// FlipCell changes the cell at (x, y) between alive and dead in the given turn, recording
+// its history to colour it by.
+func (w *Window) FlipCell(x, y, turn int) {
+	if !w.Contains(x, y) {
+		panic(fmt.Sprintf("CellFlipped event at (%d, %d) is outside the bounds of the window.", x, y))
+	}
+	w.board.Flip(util.Cell{X: x, Y: y}, turn)
+	w.paint(x, y)
+}
+
+// SetCellGrey gives the cell at (x, y) the grey level of its state in the given turn, for
+// cells with more than two states.
+func (w *Window) SetCellGrey(x, y int, grey uint8, turn int) {
+	if !w.Contains(x, y) {
+		panic(fmt.Sprintf("CellStateChanged event at (%d, %d) is outside the bounds of the window.", x, y))
+	}
+	w.board.Set(util.Cell{X: x, Y: y}, grey, turn)
+	w.paint(x, y)
+}
+
+// CompleteTurn moves the cells on to the given turn, so those that did not change grow
+// older.
+func (w *Window) CompleteTurn(turn int) {
+	w.board.Complete(turn)
+}
+
+// Colouring returns how cells are coloured.
+func (w *Window) Colouring() colouring.Mode {
+	return w.colour
+}
+
+// SetColouring changes how cells are coloured, from the next frame on.
+func (w *Window) SetColouring(mode colouring.Mode) {
+	w.colour = mode
+	for y := 0; y < int(w.Height); y++ {
+		for x := 0; x < int(w.Width); x++ {
+			w.paint(x, y)
+		}
+	}
+}
+
+// paint sets the pixel at (x, y) to the colour of its cell.
+func (w *Window) paint(x, y int) {
+	r, g, b := w.board.RGB(x, y, w.colour)
+	// Pixels are stored as ARGB8888 words, so in memory the bytes run B, G, R, A.
+	i := 4 * (y*int(w.Width) + x)
+	w.pixels[i+0], w.pixels[i+1], w.pixels[i+2], w.pixels[i+3] = b, g, r, 0xFF
+}
+
 func (w *Window) PollEvent() sdl.Event {
 	return sdl.PollEvent()
 }
@@ -75,6 +199,8 @@ func (w *Window) SetPixel(x, y int) {
 	w.pixels[4*(y*width+x)+3] = 0xFF
 }
 
+// FlipPixel inverts the pixel at (x, y) without recording the history of its cell, which
+// FlipCell does.
 func (w *Window) FlipPixel(x, y int) {
 	if x < 0 || y < 0 || x >= int(w.Width) || y >= int(w.Height) {
 		panic(fmt.Sprintf("CellFlipped event at (%d, %d) is outside the bounds of the window.", x, y))
@@ -87,6 +213,19 @@ func (w *Window) FlipPixel(x, y int) {
 	w.pixels[4*(y*width+x)+3] = ^w.pixels[4*(y*width+x)+3]
 }
 
+// SetPixelGrey sets a pixel to a grey level, for cells with more than two states.
+func (w *Window) SetPixelGrey(x, y int, grey uint8) {
+	if x < 0 || y < 0 || x >= int(w.Width) || y >= int(w.Height) {
+		panic(fmt.Sprintf("CellStateChanged event at (%d, %d) is outside the bounds of the window.", x, y))
+	}
+
+	width := int(w.Width)
+	w.pixels[4*(y*width+x)+0] = grey
+	w.pixels[4*(y*width+x)+1] = grey
+	w.pixels[4*(y*width+x)+2] = grey
+	w.pixels[4*(y*width+x)+3] = 0xFF
+}
+
 func (w *Window) CountPixels() int {
 	count := 0
 	for i := 0; i < int(w.Width)*int(w.Height)*4; i += 4 {
@@ -6,6 +6,28 @@ const (
 	GameOfLife    = "Worker.GameOfLife"
 	GetAliveCells = "Worker.GetAliveCells"
 	KeyPress      = "Worker.KeyPress"
+	// Start begins a session without waiting for it; GetFlips then fetches its turns as they complete.
+	Start    = "Worker.Start"
+	GetFlips = "Worker.GetFlips"
+	// Attach and Wait let a new controller take over a session another one detached from with q.
+	Attach = "Worker.Attach"
+	Wait   = "Worker.Wait"
+	// Edit sets cells of a running session's world, which then carries on from the edited world.
+	Edit = "Worker.Edit"
+	// Control steps a paused session by a turn, limits its speed or sets a turn for it to pause at.
+	Control = "Worker.Control"
+	// CalculateStrip, Ping and Shutdown are called by the broker on each registered worker.
+	CalculateStrip = "Worker.CalculateStrip"
+	Ping           = "Worker.Ping"
+	Shutdown       = "Worker.Shutdown"
+	// LoadStrip, StepStrip and GetStrip are called by a broker using halo exchange;
+	// GetHalo is called by a worker on its neighbours.
+	LoadStrip = "Worker.LoadStrip"
+	StepStrip = "Worker.StepStrip"
+	GetStrip  = "Worker.GetStrip"
+	GetHalo   = "Worker.GetHalo"
+	// Register is called by a worker on the broker at start-up.
+	Register = "Broker.Register"
 )
 
 type Params struct {
@@ -13,17 +35,120 @@ type Params struct {
 	ImageHeight int
 	Turns       int
 	Threads     int
+	// Rule is a Life-like rule such as "B36/S23"; empty means Conway's B3/S23.
+	Rule string
+	// Topology is "torus", "plane", "klein" or "cross"; empty means a torus.
+	Topology string
+	// Algorithm is "sparse" for an unbounded world holding only its live cells, or "hashlife"
+	// to advance a square torus many turns at a time; empty means stepping the fixed-size
+	// world given by the image one turn at a time.
+	Algorithm string
 }
 
 type GameOfLifeRequest struct {
-	World  [][]uint8
+	World  *util.Grid
 	Params Params
 }
 
 type GameOfLifeResponse struct {
-	World      [][]uint8
+	World      *util.Grid
 	Turns      int
 	AliveCells []util.Cell
+	Session    string
+	// Detached is set when the controller pressed q and the session is still running.
+	Detached bool
+}
+
+type StartResponse struct {
+	Session string
+	// Turn and World are set when the session resumes from a checkpoint rather than the given world.
+	Turn  int
+	World *util.Grid
+}
+
+// TurnFlips lists the cells flipped by a turn, or by an edit made after it.
+type TurnFlips struct {
+	Turn  int
+	Cells []util.Cell
+	// Edits is how many edits had been made to the session when the entry was added.
+	Edits int
+	// Edit is set when the cells were changed by an edit rather than a turn, in which case
+	// they were all set alive, or dead if Alive is false.
+	Edit  bool
+	Alive bool
+	// Paused is set on the turn the session was to run until, when it paused itself.
+	Paused bool
+}
+
+type GetFlipsRequest struct {
+	Session string
+	// SinceTurn is the last turn the controller has received, and SinceEdits the Edits of
+	// the last entry it has received.
+	SinceTurn  int
+	SinceEdits int
+}
+
+type GetFlipsResponse struct {
+	Turns []TurnFlips
+	// Done is set once the session has finished or the controller has detached; Turns holds the last of them.
+	Done bool
+}
+
+type AttachRequest struct {
+	// Session names the session to attach to. If empty, any session with the same Params is used.
+	Session string
+	Params  Params
+}
+
+type AttachResponse struct {
+	Found   bool
+	Session string
+	Params  Params
+	Turn    int
+	Paused  bool
+	World   *util.Grid
+	// TurnsPerSecond and Until are as in ControlResponse.
+	TurnsPerSecond float64
+	Until          int
+}
+
+type WaitRequest struct {
+	Session string
+}
+
+type EditRequest struct {
+	Session string
+	Cells   []util.Cell
+	// Alive is set to make the cells alive, and clear to make them dead.
+	Alive bool
+}
+
+type EditResponse struct {
+	Turn int
+	// Changed is how many of the cells were not already in the state asked for.
+	Changed int
+}
+
+// ControlRequest changes how a session steps. Fields left at zero are not changed.
+type ControlRequest struct {
+	Session string
+	// Step advances a paused session by exactly one turn.
+	Step bool
+	// TurnsPerSecond limits the session to that many turns a second when positive, and
+	// lifts the limit when negative.
+	TurnsPerSecond float64
+	// Until makes the session pause once it reaches that turn when positive, and forgets
+	// the turn when negative.
+	Until int
+}
+
+type ControlResponse struct {
+	Turn   int
+	Paused bool
+	// TurnsPerSecond is the session's speed limit, or 0 if it has none, and Until the turn
+	// it will pause at, or 0 if none.
+	TurnsPerSecond float64
+	Until          int
 }
 
 type GetAliveCellsRequest struct {
@@ -39,8 +164,91 @@ type KeyPressRequest struct {
 }
 
 type KeyPressResponse struct {
-	World      [][]uint8
+	World      *util.Grid
 	Turn       int
 	Paused     bool
 	AliveCells []util.Cell
+	// TurnsPerSecond and Until are set by the keys that step and change the speed, as in
+	// ControlResponse.
+	TurnsPerSecond float64
+	Until          int
+}
+
+type StripRequest struct {
+	Params Params
+	StartY int
+	EndY   int
+	// Rows holds rows StartY to EndY-1 and their halo rows, keyed by row index.
+	// Rows here and in the strip messages below are packed as in util.Grid.
+	Rows map[int][]byte
+}
+
+type StripResponse struct {
+	Strip   [][]byte
+	Flipped []util.Cell
+}
+
+type PingRequest struct {
+}
+
+type PingResponse struct {
+}
+
+type ShutdownRequest struct {
+}
+
+type ShutdownResponse struct {
+}
+
+type RegisterRequest struct {
+	Address string
+}
+
+type RegisterResponse struct {
+	Workers int
+}
+
+// Peer describes the strip of the world owned by one worker.
+type Peer struct {
+	Address string
+	StartY  int
+	EndY    int
+}
+
+type LoadStripRequest struct {
+	Params Params
+	Turn   int
+	// Index is the receiving worker's position in Peers.
+	Index int
+	Peers []Peer
+	Strip [][]byte
+}
+
+type LoadStripResponse struct {
+}
+
+type StepStripRequest struct {
+	Turn int
+}
+
+type StepStripResponse struct {
+	Flipped []util.Cell
+}
+
+type HaloRequest struct {
+	Turn int
+	Y    int
+}
+
+type HaloResponse struct {
+	Row []byte
+}
+
+type GetStripRequest struct {
+}
+
+type GetStripResponse struct {
+	Turn   int
+	StartY int
+	Strip  [][]byte
 }
@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// terminal is the controlling terminal, put into raw mode so keys arrive as they are
+// pressed and are not echoed.
+type terminal struct {
+	tty *os.File
+	// saved is the terminal's settings from before, as printed by stty -g.
+	saved string
+}
+
+// openTerminal opens the controlling terminal, switches it to raw mode and to its
+// alternate screen, and hides the cursor.
+func openTerminal() (*terminal, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal to draw in: %v", err)
+	}
+	t := &terminal{tty: tty}
+	saved, err := t.stty("-g")
+	if err != nil {
+		tty.Close()
+		return nil, err
+	}
+	t.saved = strings.TrimSpace(saved)
+	if _, err := t.stty("raw", "-echo"); err != nil {
+		tty.Close()
+		return nil, err
+	}
+	t.tty.WriteString("\x1b[?1049h\x1b[?25l\x1b[2J")
+	return t, nil
+}
+
+// stty runs stty on the terminal with the given arguments, returning what it prints.
+func (t *terminal) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.tty
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty %s failed: %v", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// size returns the number of columns and rows of the terminal.
+func (t *terminal) size() (int, int, error) {
+	out, err := t.stty("size")
+	if err != nil {
+		return 0, 0, err
+	}
+	var rows, cols int
+	if _, err := fmt.Sscan(out, &rows, &cols); err != nil || rows < 1 || cols < 1 {
+		return 0, 0, fmt.Errorf("unexpected terminal size %q", strings.TrimSpace(out))
+	}
+	return cols, rows, nil
+}
+
+// readKeys sends each key read from the terminal to keys until reading fails. Arrow keys
+// are sent as the runes up, down, left and right.
+func (t *terminal) readKeys(keys chan<- rune) {
+	buf := make([]byte, 64)
+	for {
+		n, err := t.tty.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		in := buf[:n]
+		for len(in) > 0 {
+			if len(in) >= 3 && in[0] == 0x1b && (in[1] == '[' || in[1] == 'O') {
+				switch in[2] {
+				case 'A':
+					keys <- up
+				case 'B':
+					keys <- down
+				case 'C':
+					keys <- right
+				case 'D':
+					keys <- left
+				}
+				in = in[3:]
+				continue
+			}
+			keys <- rune(in[0])
+			in = in[1:]
+		}
+	}
+}
+
+// restore shows the cursor, leaves the alternate screen and puts back the terminal's
+// settings.
+func (t *terminal) restore() {
+	t.tty.WriteString("\x1b[0m\x1b[?25h\x1b[?1049l")
+	t.stty(t.saved)
+	t.tty.Close()
+}
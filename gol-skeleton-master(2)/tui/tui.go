@@ -0,0 +1,327 @@
+// Package tui is a visualiser that draws the world in a terminal, for machines without
+// SDL. Cells are drawn as half blocks, two to a character, or as braille dots, eight to a
+// character, and a large world can be zoomed out or panned around.
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Arrow keys are read as runes from the Unicode private use area.
+const (
+	up rune = 0xE000 + iota
+	down
+	left
+	right
+)
+
+const (
+	// frameInterval is the least time between frames, so a fast simulation is not held
+	// back by drawing every turn.
+	frameInterval = 33 * time.Millisecond
+	// resizeInterval is how often the terminal's size is checked.
+	resizeInterval = time.Second
+)
+
+const help = "p pause  n step  [/] slower/faster  123u run until  s save  q quit  k kill  arrows pan  +/- zoom  f fit  b braille"
+
+// viewer is the world as the events have described it, and the part of it on screen.
+type viewer struct {
+	width, height int
+	greys         []byte
+	alive         int
+
+	turn    int
+	state   string
+	message string
+
+	cols, rows int
+	// braille draws 2x4 dots to a character rather than 1x2 half blocks.
+	braille bool
+	// zoom is the number of cells across and down each dot covers. A dot is drawn if any
+	// of them is not dead.
+	zoom int
+	// x and y are the cell at the top left of the screen.
+	x, y int
+}
+
+func (v *viewer) set(cell util.Cell, grey byte) {
+	if cell.X < 0 || cell.X >= v.width || cell.Y < 0 || cell.Y >= v.height {
+		return
+	}
+	i := cell.Y*v.width + cell.X
+	if v.greys[i] == 0 && grey != 0 {
+		v.alive++
+	} else if v.greys[i] != 0 && grey == 0 {
+		v.alive--
+	}
+	v.greys[i] = grey
+}
+
+func (v *viewer) flip(cell util.Cell) {
+	if cell.X < 0 || cell.X >= v.width || cell.Y < 0 || cell.Y >= v.height {
+		return
+	}
+	v.set(cell, ^v.greys[cell.Y*v.width+cell.X])
+}
+
+// dotSize returns how many dots across and down a character holds.
+func (v *viewer) dotSize() (int, int) {
+	if v.braille {
+		return 2, 4
+	}
+	return 1, 2
+}
+
+// screen returns the number of dots across and down the screen, less the status line.
+func (v *viewer) screen() (int, int) {
+	dx, dy := v.dotSize()
+	return v.cols * dx, (v.rows - 1) * dy
+}
+
+// fitZoom returns the least zoom at which the whole world is on screen.
+func (v *viewer) fitZoom() int {
+	across, down := v.screen()
+	if across < 1 || down < 1 {
+		return 1
+	}
+	zoom := (v.width + across - 1) / across
+	if z := (v.height + down - 1) / down; z > zoom {
+		zoom = z
+	}
+	if zoom < 1 {
+		zoom = 1
+	}
+	return zoom
+}
+
+// clamp keeps the zoom between one cell a dot and the whole world on screen, and keeps
+// the view within the world.
+func (v *viewer) clamp() {
+	if fit := v.fitZoom(); v.zoom > fit {
+		v.zoom = fit
+	}
+	if v.zoom < 1 {
+		v.zoom = 1
+	}
+	across, down := v.screen()
+	v.x = clampInt(v.x, 0, v.width-across*v.zoom)
+	v.y = clampInt(v.y, 0, v.height-down*v.zoom)
+}
+
+func clampInt(n, lo, hi int) int {
+	if n > hi {
+		n = hi
+	}
+	if n < lo {
+		n = lo
+	}
+	return n
+}
+
+// zoomBy changes the zoom, keeping the cell at the centre of the screen where it is.
+func (v *viewer) zoomBy(zoom int) {
+	across, down := v.screen()
+	cx, cy := v.x+across*v.zoom/2, v.y+down*v.zoom/2
+	v.zoom = zoom
+	v.clamp()
+	v.x, v.y = cx-across*v.zoom/2, cy-down*v.zoom/2
+	v.clamp()
+}
+
+// pan moves the view by a quarter of the screen in each direction given.
+func (v *viewer) pan(dx, dy int) {
+	across, down := v.screen()
+	v.x += dx * (across*v.zoom/4 + 1)
+	v.y += dy * (down*v.zoom/4 + 1)
+	v.clamp()
+}
+
+// lit reports whether the dot at column dx and row dy of the screen is drawn.
+func (v *viewer) lit(dx, dy int) bool {
+	x0, y0 := v.x+dx*v.zoom, v.y+dy*v.zoom
+	for y := y0; y < y0+v.zoom && y < v.height; y++ {
+		row := v.greys[y*v.width:]
+		for x := x0; x < x0+v.zoom && x < v.width; x++ {
+			if row[x] != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// brailleDots are the bits of a braille character for each dot, indexed by row then column.
+var brailleDots = [4][2]rune{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+
+// draw returns the escape codes and characters that redraw the whole screen.
+func (v *viewer) draw() string {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	dx, dy := v.dotSize()
+	for row := 0; row < v.rows-1; row++ {
+		for col := 0; col < v.cols; col++ {
+			if v.braille {
+				glyph := rune(0x2800)
+				for y := 0; y < dy; y++ {
+					for x := 0; x < dx; x++ {
+						if v.lit(col*dx+x, row*dy+y) {
+							glyph |= brailleDots[y][x]
+						}
+					}
+				}
+				b.WriteRune(glyph)
+				continue
+			}
+			top, bottom := v.lit(col, 2*row), v.lit(col, 2*row+1)
+			switch {
+			case top && bottom:
+				b.WriteRune('█')
+			case top:
+				b.WriteRune('▀')
+			case bottom:
+				b.WriteRune('▄')
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString("\x1b[K\r\n")
+	}
+	status := fmt.Sprintf(" Turn %d  Alive %d  %s  zoom 1:%d at %d,%d  %s  %s",
+		v.turn, v.alive, v.state, v.zoom, v.x, v.y, v.message, help)
+	if len(status) > v.cols {
+		status = status[:v.cols]
+	}
+	b.WriteString("\x1b[7m")
+	b.WriteString(status)
+	b.WriteString("\x1b[K\x1b[0m")
+	return b.String()
+}
+
+// key handles a key pressed in the terminal, sending the controller's keys on to it. It
+// reports whether the screen needs redrawing.
+func (v *viewer) key(key rune, keyPresses chan<- rune) bool {
+	switch key {
+	case 'p', 's', 'q', 'k', 'n', '[', ']', 'u', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		keyPresses <- key
+		return false
+	case 3:
+		// Ctrl-C does not interrupt in raw mode, so it quits as q does.
+		keyPresses <- 'q'
+		return false
+	case up, 'K':
+		v.pan(0, -1)
+	case down, 'J':
+		v.pan(0, 1)
+	case left, 'H':
+		v.pan(-1, 0)
+	case right, 'L':
+		v.pan(1, 0)
+	case '+', '=':
+		v.zoomBy(v.zoom / 2)
+	case '-', '_':
+		v.zoomBy(v.zoom * 2)
+	case 'f':
+		v.zoomBy(v.fitZoom())
+	case 'b':
+		v.braille = !v.braille
+		v.clamp()
+	default:
+		return false
+	}
+	return true
+}
+
+// Run draws the events in the terminal until FinalTurnComplete, in place of sdl.Run. Keys
+// pressed in the terminal are sent to keyPresses as they would be from the SDL window.
+func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) error {
+	t, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer t.restore()
+	v := &viewer{
+		width:  p.ImageWidth,
+		height: p.ImageHeight,
+		greys:  make([]byte, p.ImageWidth*p.ImageHeight),
+		state:  gol.Executing.String(),
+	}
+	if v.cols, v.rows, err = t.size(); err != nil {
+		return err
+	}
+	v.zoom = v.fitZoom()
+
+	keys := make(chan rune, 16)
+	go t.readKeys(keys)
+	resize := time.NewTicker(resizeInterval)
+	defer resize.Stop()
+
+	// wait fires when the screen is next due to be drawn, and is nil while it is up to
+	// date. drawn is when it was last drawn.
+	var wait <-chan time.Time
+	var drawn time.Time
+	render := func() {
+		t.tty.WriteString(v.draw())
+		wait = nil
+		drawn = time.Now()
+	}
+	update := func() {
+		if wait == nil {
+			wait = time.After(frameInterval - time.Since(drawn))
+		}
+	}
+	render()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			switch e := event.(type) {
+			case gol.CellFlipped:
+				v.flip(e.Cell)
+			case gol.CellStateChanged:
+				v.set(e.Cell, util.StateGrey(e.State, e.States))
+			case gol.TurnComplete:
+				v.turn = e.CompletedTurns
+				update()
+			case gol.FinalTurnComplete:
+				return nil
+			case gol.StateChange:
+				v.turn = e.CompletedTurns
+				v.state = e.String()
+				update()
+			case gol.AliveCellsCount:
+				// The cells on screen are counted as they change, so this is not shown.
+			default:
+				if len(event.String()) > 0 {
+					v.message = event.String()
+					update()
+				}
+			}
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+				continue
+			}
+			if v.key(key, keyPresses) {
+				render()
+			}
+		case <-resize.C:
+			cols, rows, err := t.size()
+			if err == nil && (cols != v.cols || rows != v.rows) {
+				v.cols, v.rows = cols, rows
+				v.clamp()
+				t.tty.WriteString("\x1b[2J")
+				render()
+			}
+		case <-wait:
+			render()
+		}
+	}
+}
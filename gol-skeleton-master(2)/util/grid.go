@@ -0,0 +1,180 @@
+package util
+
+import "math/bits"
+
+// Grid is a world packed Bits bits per cell: the state of cell (x, y) is held in bits
+// x*Bits%8 upwards of Rows[y][x*Bits/8]. State 0 is dead and 1 is alive; Generations rules
+// add dying states from 2 upwards. It is used by the engine and in RPC messages in place
+// of one byte per cell. Rows may be a partial view of a larger world, in which case absent
+// rows are nil.
+type Grid struct {
+	Width  int
+	Height int
+	// Bits is 1, 2, 4 or 8; 0 is taken as 1.
+	Bits int
+	Rows [][]byte
+	// OriginX and OriginY are the world coordinates of cell (0, 0). They are only non-zero
+	// for the bounding box of an unbounded world.
+	OriginX int
+	OriginY int
+}
+
+// NewGrid returns a grid of two-state cells, every one dead.
+func NewGrid(width, height int) *Grid {
+	return NewStateGrid(width, height, 2)
+}
+
+// NewStateGrid returns a grid with room for the given number of cell states, every cell dead.
+func NewStateGrid(width, height, states int) *Grid {
+	g := &Grid{Width: width, Height: height, Bits: BitsFor(states), Rows: make([][]byte, height)}
+	for y := range g.Rows {
+		g.Rows[y] = NewRow(width, g.Bits)
+	}
+	return g
+}
+
+// BitsFor returns the bits per cell needed for the given number of states.
+func BitsFor(states int) int {
+	n := 1
+	for 1<<uint(n) < states {
+		n *= 2
+	}
+	return n
+}
+
+// NewRow returns a packed row of width dead cells.
+func NewRow(width, bits int) []byte {
+	return make([]byte, (width*bits+7)/8)
+}
+
+// RowState returns the state of cell x of a packed row.
+func RowState(row []byte, x, bits int) uint8 {
+	i := x * bits
+	return row[i>>3] >> uint(i&7) & uint8(1<<uint(bits)-1)
+}
+
+// SetRowState sets the state of cell x of a packed row.
+func SetRowState(row []byte, x, bits int, state uint8) {
+	i := x * bits
+	mask := uint8(1<<uint(bits)-1) << uint(i&7)
+	row[i>>3] = row[i>>3]&^mask | state<<uint(i&7)&mask
+}
+
+// CellBits returns the bits per cell, taking 0 as 1.
+func (g *Grid) CellBits() int {
+	if g.Bits == 0 {
+		return 1
+	}
+	return g.Bits
+}
+
+// State returns the state of cell (x, y).
+func (g *Grid) State(x, y int) uint8 {
+	return RowState(g.Rows[y], x, g.CellBits())
+}
+
+// SetState sets the state of cell (x, y).
+func (g *Grid) SetState(x, y int, state uint8) {
+	SetRowState(g.Rows[y], x, g.CellBits(), state)
+}
+
+// Alive reports whether cell (x, y) is alive.
+func (g *Grid) Alive(x, y int) bool {
+	return g.State(x, y) == 1
+}
+
+// Set sets cell (x, y) to alive or dead.
+func (g *Grid) Set(x, y int, alive bool) {
+	if alive {
+		g.SetState(x, y, 1)
+	} else {
+		g.SetState(x, y, 0)
+	}
+}
+
+// StateAt returns the state of the cell at world coordinates (x, y), which is dead if it
+// lies outside the grid.
+func (g *Grid) StateAt(x, y int) uint8 {
+	x, y = x-g.OriginX, y-g.OriginY
+	if x < 0 || x >= g.Width || y < 0 || y >= g.Height || g.Rows[y] == nil {
+		return 0
+	}
+	return g.State(x, y)
+}
+
+// AliveCells lists every alive cell in world coordinates, row by row.
+func (g *Grid) AliveCells() []Cell {
+	var cells []Cell
+	for y, row := range g.Rows {
+		for x := 0; x < g.Width; x++ {
+			if RowState(row, x, g.CellBits()) == 1 {
+				cells = append(cells, Cell{X: x + g.OriginX, Y: y + g.OriginY})
+			}
+		}
+	}
+	return cells
+}
+
+// Count returns the number of alive cells; a nil grid has none.
+func (g *Grid) Count() int {
+	if g == nil {
+		return 0
+	}
+	if g.CellBits() > 1 {
+		return len(g.AliveCells())
+	}
+	count := 0
+	for _, row := range g.Rows {
+		for _, b := range row {
+			count += bits.OnesCount8(b)
+		}
+	}
+	return count
+}
+
+// Clone returns a copy of g that shares no memory with it.
+func (g *Grid) Clone() *Grid {
+	c := &Grid{Width: g.Width, Height: g.Height, Bits: g.Bits, Rows: make([][]byte, len(g.Rows)),
+		OriginX: g.OriginX, OriginY: g.OriginY}
+	for y, row := range g.Rows {
+		if row != nil {
+			c.Rows[y] = append([]byte(nil), row...)
+		}
+	}
+	return c
+}
+
+// StateGrey returns the grey level a cell state is drawn and saved with: dead cells are
+// black, alive cells white, and dying cells fade from white to black as they age.
+func StateGrey(state uint8, states int) uint8 {
+	switch {
+	case state == 0:
+		return 0
+	case state == 1 || states <= 2:
+		return 255
+	default:
+		return uint8(255 * (states - int(state)) / (states - 1))
+	}
+}
+
+// GreyState is the inverse of StateGrey, mapping other grey levels to the nearest state.
+// With two states only 255 is alive, as in the original PGM images.
+func GreyState(grey uint8, states int) uint8 {
+	if grey == 255 {
+		return 1
+	}
+	if grey == 0 || states <= 2 {
+		return 0
+	}
+	best, bestDistance := uint8(0), int(grey)
+	for state := 2; state < states; state++ {
+		distance := int(grey) - int(StateGrey(uint8(state), states))
+		if distance < 0 {
+			distance = -distance
+		}
+		if distance < bestDistance {
+			best, bestDistance = uint8(state), distance
+		}
+	}
+	return best
+}
@@ -0,0 +1,205 @@
+package util
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// PNM is a decoded PBM or PGM image. Pixels holds the grey level of each pixel, row by row,
+// from 0 for black to MaxVal for white. PBM images have a MaxVal of 1, and as their 1 bits
+// are black they are stored as 0.
+type PNM struct {
+	Width  int
+	Height int
+	MaxVal int
+	Pixels []uint16
+}
+
+// maxPixels bounds the size of image ReadPNM accepts, so a corrupt header cannot make it
+// allocate without limit.
+const maxPixels = 1 << 30
+
+var errPNMShort = errors.New("pnm image data ends early")
+
+// pnmReader reads the parts of a PNM file.
+type pnmReader struct {
+	r *bufio.Reader
+}
+
+// ReadPNM decodes a plain or raw PBM or PGM image (P1, P2, P4 or P5) from r. Comments may
+// appear anywhere in the header, and in the data of plain images.
+func ReadPNM(r io.Reader) (*PNM, error) {
+	p := pnmReader{bufio.NewReader(r)}
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(p.r, magic); err != nil || magic[0] != 'P' {
+		return nil, errors.New("not a pnm image")
+	}
+	kind := magic[1]
+	if kind != '1' && kind != '2' && kind != '4' && kind != '5' {
+		return nil, fmt.Errorf("unsupported pnm image type P%c: want P1, P2, P4 or P5", kind)
+	}
+	image := &PNM{MaxVal: 1}
+	var err error
+	if image.Width, err = p.number(); err != nil {
+		return nil, err
+	}
+	if image.Height, err = p.number(); err != nil {
+		return nil, err
+	}
+	if image.Width*image.Height > maxPixels || image.Width > maxPixels || image.Height > maxPixels {
+		return nil, fmt.Errorf("pnm image is too large at %dx%d", image.Width, image.Height)
+	}
+	if kind == '2' || kind == '5' {
+		if image.MaxVal, err = p.number(); err != nil {
+			return nil, err
+		}
+		if image.MaxVal < 1 || image.MaxVal > 65535 {
+			return nil, fmt.Errorf("invalid pnm maxval %d: want 1 to 65535", image.MaxVal)
+		}
+	}
+	image.Pixels = make([]uint16, image.Width*image.Height)
+	switch kind {
+	case '1':
+		err = p.plainBits(image)
+	case '2':
+		err = p.plainGreys(image)
+	case '4':
+		err = p.rawBits(image)
+	case '5':
+		err = p.rawGreys(image)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// skip skips whitespace and comments, returning the next byte.
+func (p pnmReader) skip() (byte, error) {
+	for {
+		c, err := p.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c == '#' {
+			if _, err := p.r.ReadString('\n'); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if !isSpace(c) {
+			return c, nil
+		}
+	}
+}
+
+// number reads a decimal number from the header or plain data. The single whitespace
+// character that ends it is consumed, so raw data starts straight after the header.
+func (p pnmReader) number() (int, error) {
+	c, err := p.skip()
+	if err != nil {
+		return 0, errPNMShort
+	}
+	var digits []byte
+	for c >= '0' && c <= '9' {
+		digits = append(digits, c)
+		if len(digits) > 9 {
+			return 0, errors.New("number in pnm image is too large")
+		}
+		if c, err = p.r.ReadByte(); err != nil {
+			break
+		}
+	}
+	if len(digits) == 0 {
+		return 0, fmt.Errorf("unexpected %q in pnm image", c)
+	}
+	if err == nil {
+		switch {
+		case c == '#':
+			if _, err := p.r.ReadString('\n'); err != nil {
+				return 0, errPNMShort
+			}
+		case !isSpace(c):
+			return 0, fmt.Errorf("unexpected %q in pnm image", c)
+		}
+	}
+	return strconv.Atoi(string(digits))
+}
+
+func (p pnmReader) plainBits(image *PNM) error {
+	for i := range image.Pixels {
+		c, err := p.skip()
+		if err != nil {
+			return errPNMShort
+		}
+		switch c {
+		case '0':
+			image.Pixels[i] = 1
+		case '1':
+			image.Pixels[i] = 0
+		default:
+			return fmt.Errorf("unexpected %q in pbm image data", c)
+		}
+	}
+	return nil
+}
+
+func (p pnmReader) plainGreys(image *PNM) error {
+	for i := range image.Pixels {
+		grey, err := p.number()
+		if err != nil {
+			return err
+		}
+		if grey > image.MaxVal {
+			return fmt.Errorf("grey level %d in pgm image is above its maxval %d", grey, image.MaxVal)
+		}
+		image.Pixels[i] = uint16(grey)
+	}
+	return nil
+}
+
+func (p pnmReader) rawBits(image *PNM) error {
+	row := make([]byte, (image.Width+7)/8)
+	for y := 0; y < image.Height; y++ {
+		if _, err := io.ReadFull(p.r, row); err != nil {
+			return errPNMShort
+		}
+		for x := 0; x < image.Width; x++ {
+			if row[x/8]&(0x80>>uint(x%8)) == 0 {
+				image.Pixels[y*image.Width+x] = 1
+			}
+		}
+	}
+	return nil
+}
+
+func (p pnmReader) rawGreys(image *PNM) error {
+	size := 1
+	if image.MaxVal > 255 {
+		size = 2
+	}
+	row := make([]byte, image.Width*size)
+	for y := 0; y < image.Height; y++ {
+		if _, err := io.ReadFull(p.r, row); err != nil {
+			return errPNMShort
+		}
+		for x := 0; x < image.Width; x++ {
+			grey := uint16(row[x])
+			if size == 2 {
+				grey = uint16(row[2*x])<<8 | uint16(row[2*x+1])
+			}
+			if int(grey) > image.MaxVal {
+				return fmt.Errorf("grey level %d in pgm image is above its maxval %d", grey, image.MaxVal)
+			}
+			image.Pixels[y*image.Width+x] = grey
+		}
+	}
+	return nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
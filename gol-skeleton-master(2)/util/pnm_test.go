@@ -0,0 +1,81 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadPNM(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  *PNM
+	}{
+		{"P5", "P5 3 1 255\n\x00\xff\x80",
+			&PNM{3, 1, 255, []uint16{0, 255, 128}}},
+		{"P5 comments", "P5\n# made by hand\n3 # width\n1\n#maxval next\n255\n\x00\xff\x80",
+			&PNM{3, 1, 255, []uint16{0, 255, 128}}},
+		{"P5 whitespace and # bytes", "P5 5 1 255\n\x09\x0a\x20\x23\x0d",
+			&PNM{5, 1, 255, []uint16{0x09, 0x0a, 0x20, 0x23, 0x0d}}},
+		{"P5 # byte first", "P5 2 1 255\n\x23\x0a",
+			&PNM{2, 1, 255, []uint16{0x23, 0x0a}}},
+		{"P5 16-bit", "P5 2 2 1000\n\x00\x00\x03\xe8\x01\x00\x00\x23",
+			&PNM{2, 2, 1000, []uint16{0, 1000, 256, 0x23}}},
+		{"P2", "P2\n3 2\n255\n0 255 0\n255 0 255\n",
+			&PNM{3, 2, 255, []uint16{0, 255, 0, 255, 0, 255}}},
+		{"P2 comments", "P2 # plain\n3 2\n# maxval\n15\n0 15 #first row\n0\n# second row\n15 0 15",
+			&PNM{3, 2, 15, []uint16{0, 15, 0, 15, 0, 15}}},
+		{"P2 16-bit", "P2 2 1 65535 65535 300",
+			&PNM{2, 1, 65535, []uint16{65535, 300}}},
+		{"P1", "P1\n# pbm\n3 2\n1 0 1\n010",
+			&PNM{3, 2, 1, []uint16{0, 1, 0, 1, 0, 1}}},
+		{"P4", "P4 10 1\n\xa0\x40",
+			&PNM{10, 1, 1, []uint16{0, 1, 0, 1, 1, 1, 1, 1, 1, 0}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			image, err := ReadPNM(strings.NewReader(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(image, test.want) {
+				t.Errorf("got %+v, want %+v", image, test.want)
+			}
+		})
+	}
+}
+
+func TestReadPNMErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"not pnm", "GIF89a"},
+		{"P3", "P3 1 1 255 0 0 0"},
+		{"header ends", "P5 3"},
+		{"no maxval", "P5 3 1"},
+		{"comment ends", "P5 3 1 # maxval"},
+		{"zero maxval", "P5 1 1 0\n\x00"},
+		{"maxval too large", "P5 1 1 65536\n\x00\x00"},
+		{"too large", "P5 100000 100000 255\n"},
+		{"P5 data ends", "P5 3 1 255\n\x00\xff"},
+		{"P5 no data", "P5 3 1 255\n"},
+		{"P5 16-bit data ends", "P5 2 1 1000\n\x00\x00\x03"},
+		{"P5 above maxval", "P5 1 1 100\n\xff"},
+		{"P2 data ends", "P2 3 1 255 0 255"},
+		{"P2 above maxval", "P2 1 1 15 16"},
+		{"P2 not a number", "P2 1 1 15 x"},
+		{"P1 data ends", "P1 3 1 1 0"},
+		{"P1 not a bit", "P1 1 1 2"},
+		{"P4 data ends", "P4 16 2\n\x00\x00\x00"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if image, err := ReadPNM(strings.NewReader(test.input)); err == nil {
+				t.Errorf("got %+v, want an error", image)
+			}
+		})
+	}
+}